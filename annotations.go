@@ -0,0 +1,88 @@
+package pgxjrep
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// FormatError is returned when value written to column does not match its "@format" annotation
+type FormatError struct {
+	Relation string
+	Column   string
+	Format   string
+}
+
+func (e *FormatError) Error() string {
+	return fmt.Sprintf("column %s of relation %s must be a valid %s", e.Column, e.Relation, e.Format)
+}
+
+var uuidRegexp = regexp.MustCompile(`^[0-9a-fA-F]{8}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{12}$`)
+
+var formats = map[string]func(value string) bool{
+	"email": func(value string) bool {
+		addr, err := mail.ParseAddress(value)
+		return err == nil && addr.Address == value
+	},
+	"uri": func(value string) bool {
+		u, err := url.ParseRequestURI(value)
+		return err == nil && u.Scheme != ""
+	},
+	"uuid": uuidRegexp.MatchString,
+}
+
+// annotation returns value of "@key:value" annotation in column comment,
+// ok is true for bare "@key" annotation as well
+func annotation(comment string, key string) (value string, ok bool) {
+	for _, v := range strings.Fields(comment) {
+		if !strings.HasPrefix(v, "@") {
+			continue
+		}
+		parts := strings.SplitN(v[1:], ":", 2)
+		if parts[0] != key {
+			continue
+		}
+		if len(parts) == 2 {
+			return parts[1], true
+		}
+		return "", true
+	}
+
+	return "", false
+}
+
+// annotate applies column comment annotations: "@hidden" columns are not readable through statements,
+// "@readonly" columns are not writable, "@format:name" validates written values, "@json:name" is applied by mapColumns
+func (c *ColumnSchema) annotate() {
+	if _, ok := annotation(c.ColumnComment, "hidden"); ok {
+		c.IsHidden = true
+	}
+	if _, ok := annotation(c.ColumnComment, "readonly"); ok {
+		c.IsReadonly = true
+		c.readonly = true
+	}
+	if format, ok := annotation(c.ColumnComment, "format"); ok {
+		if _, known := formats[format]; known {
+			c.Format = format
+		} else {
+			log.Warningf("Unknown format %s of column %s.%s.%s", format, c.SchemaName, c.RelationName, c.ColumnName)
+		}
+	}
+}
+
+// validate fails the build when string value does not match column format or enum value is not a label of its type
+func (s *DbSchema) validate(relation string, cols []ColumnData) {
+	for _, v := range cols {
+		if v.isEnum() && v.Value != nil {
+			s.checkEnum(v, v.Value)
+		}
+		if v.format == "" {
+			continue
+		}
+		if str, ok := v.Value.(string); ok && !formats[v.format](str) {
+			throw(&FormatError{Relation: relation, Column: v.JsonName, Format: v.format})
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package pgxjrep
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// array operator keys, $contains is shared with json columns and $remove with json updates
+const (
+	ContainedByKey = "$containedBy"
+	OverlapsKey    = "$overlaps"
+	AnyKey         = "$any"
+	AppendKey      = "$append"
+)
+
+func (v ColumnData) isArray() bool {
+	return v.dimension > 0
+}
+
+// elemType returns element type of array column
+func (v ColumnData) elemType() string {
+	t := v.dataType
+	for strings.HasSuffix(t, "[]") {
+		t = strings.TrimSuffix(t, "[]")
+	}
+
+	return t
+}
+
+// arrayType returns array type of column, array of its type for scalar columns
+func (v ColumnData) arrayType() string {
+	if v.isArray() {
+		return v.dataType
+	}
+
+	return v.dataType + "[]"
+}
+
+// arrayOperator returns condition of array operator, $any matches element of array column
+// or scalar column equal to any element of value, json, range and tsvector columns are not scalar
+func (c *whereClause) arrayOperator(v ColumnData, op string, value interface{}) (string, bool) {
+	col := c.column(v)
+	switch op {
+	case ContainsKey:
+		if v.isArray() {
+			return col + " @> " + c.params.get(value) + "::" + v.arrayType(), true
+		}
+	case ContainedByKey:
+		if v.isArray() {
+			return col + " <@ " + c.params.get(value) + "::" + v.arrayType(), true
+		}
+	case OverlapsKey:
+		if v.isArray() {
+			return col + " && " + c.params.get(value) + "::" + v.arrayType(), true
+		}
+	case AnyKey:
+		if v.isArray() {
+			return c.params.get(value) + "::" + v.elemType() + " = ANY(" + col + ")", true
+		}
+		if !v.isJson() && !v.isRange() && !v.isTsvector() {
+			return col + " = ANY(" + c.params.get(value) + "::" + v.arrayType() + ")", true
+		}
+	}
+
+	return "", false
+}
+
+// arrayAssignment updates array column: $append appends element or list of elements, $remove removes
+// all occurrences of element or of each element of list
+func (s *DbSchema) arrayAssignment(col ColumnData, ops map[string]interface{}, prm *params) string {
+	keys := make([]string, 0, len(ops))
+	for k := range ops {
+		if k != AppendKey && k != RemoveKey {
+			throw(fmt.Errorf("update operator %s is not supported on column %s of type %s", k, col.JsonName, col.dataType))
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	expr := s.Quote(col.DbName)
+	for _, k := range keys {
+		value := ops[k]
+		list := isList(value)
+		switch {
+		case k == AppendKey && list:
+			expr = "array_cat(" + expr + ", " + prm.get(value) + "::" + col.dataType + ")"
+		case k == AppendKey:
+			expr = "array_append(" + expr + ", " + prm.get(value) + "::" + col.elemType() + ")"
+		case list:
+			rv := reflect.ValueOf(value)
+			for i := 0; i < rv.Len(); i++ {
+				expr = "array_remove(" + expr + ", " + prm.get(rv.Index(i).Interface()) + "::" + col.elemType() + ")"
+			}
+		default:
+			expr = "array_remove(" + expr + ", " + prm.get(value) + "::" + col.elemType() + ")"
+		}
+	}
+
+	return s.Quote(col.DbName) + " = " + expr
+}
+
+func isList(value interface{}) bool {
+	if value == nil {
+		return false
+	}
+	k := reflect.TypeOf(value).Kind()
+
+	return k == reflect.Slice || k == reflect.Array
+}
@@ -0,0 +1,74 @@
+package pgxjrep
+
+import "context"
+
+// AuditPolicy names audit columns maintained by Insert and Update statements,
+// columns missing from a relation are skipped, empty names disable particular column
+type AuditPolicy struct {
+	CreatedAt string
+	UpdatedAt string
+	CreatedBy string
+	UpdatedBy string
+}
+
+type actorKey struct{}
+
+func DefaultAuditPolicy() *AuditPolicy {
+	return &AuditPolicy{
+		CreatedAt: "created_at",
+		UpdatedAt: "updated_at",
+		CreatedBy: "created_by",
+		UpdatedBy: "updated_by",
+	}
+}
+
+// ContextWithActor returns context carrying actor written to CreatedBy and UpdatedBy columns
+func ContextWithActor(ctx context.Context, actor interface{}) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+func ActorFromContext(ctx context.Context) (interface{}, bool) {
+	actor := ctx.Value(actorKey{})
+	return actor, actor != nil
+}
+
+// protected reports whether column is maintained by the policy and can not be set by clients
+func (p *AuditPolicy) protected(column string) bool {
+	if p == nil || column == "" {
+		return false
+	}
+
+	return column == p.CreatedAt || column == p.UpdatedAt || column == p.CreatedBy || column == p.UpdatedBy
+}
+
+// assignments returns audit columns present on relation with their quoted values
+func (p *AuditPolicy) assignments(ctx context.Context, schema *DbSchema, relation string, insert bool, prm *params) ([]string, []string) {
+	var cols, vals []string
+	if p == nil {
+		return cols, vals
+	}
+
+	colMap := schema.ColMap(relation)
+	actor, hasActor := ActorFromContext(ctx)
+	add := func(col string, val func() string) {
+		if col != "" && colMap[col] {
+			cols = append(cols, schema.Quote(col))
+			vals = append(vals, val())
+		}
+	}
+	now := func() string { return "now()" }
+	actorParam := func() string { return prm.get(actor) }
+
+	if insert {
+		add(p.CreatedAt, now)
+	}
+	add(p.UpdatedAt, now)
+	if hasActor {
+		if insert {
+			add(p.CreatedBy, actorParam)
+		}
+		add(p.UpdatedBy, actorParam)
+	}
+
+	return cols, vals
+}
@@ -3,11 +3,21 @@ package pgxjrep
 import (
 	"context"
 	"fmt"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgconn/stmtcache"
 	"github.com/tidwall/gjson"
 )
 
 type Builder struct {
 	*DbSchema
+	statements    *statementCache
+	audit         *AuditPolicy
+	tenant        *TenantPolicy
+	perms         *permissions
+	softDelete    map[string]string
+	versions      map[string]string
+	resources     map[string]exposure
+	searchConfigs map[string]string
 }
 
 func NewBuilder(conn PgxConn, ctx context.Context) (*Builder, error) {
@@ -17,19 +27,120 @@ func NewBuilder(conn PgxConn, ctx context.Context) (*Builder, error) {
 	}
 
 	return &Builder{
-		DbSchema: dbSchema,
+		DbSchema:      dbSchema,
+		statements:    newStatementCache(DefaultStatementCacheSize),
+		perms:         newPermissions(dbSchema, nil),
+		softDelete:    make(map[string]string),
+		versions:      make(map[string]string),
+		resources:     make(map[string]exposure),
+		searchConfigs: make(map[string]string),
 	}, nil
 }
 
+// BuildStatementCache creates prepared statement cache of connection counted in StatementCacheStats,
+// set it as pgx.ConnConfig.BuildStatementCache, connections built while the cache is disabled get none
+func (b *Builder) BuildStatementCache(conn *pgconn.PgConn) stmtcache.Cache {
+	return b.statements.build(conn)
+}
+
+// SetStatementCacheSize sets the number of prepared statements kept per connection built afterwards, 0 disables the cache
+func (b *Builder) SetStatementCacheSize(size int) *Builder {
+	b.statements.resize(size)
+	return b
+}
+
+// DisableStatementCache leaves connections built afterwards without prepared statement cache,
+// required behind PgBouncer in transaction mode
+func (b *Builder) DisableStatementCache() *Builder {
+	b.statements.disable()
+	return b
+}
+
+// StatementCacheStats returns hits and misses of statement caches built with BuildStatementCache
+func (b *Builder) StatementCacheStats() StatementCacheStats {
+	return b.statements.stats()
+}
+
+// SetAuditPolicy enables audit columns on Insert and Update statements, nil disables them
+func (b *Builder) SetAuditPolicy(policy *AuditPolicy) *Builder {
+	b.audit = policy
+	return b
+}
+
+// SetTenantPolicy scopes every statement on relations having tenant column to tenant from context, nil disables it
+func (b *Builder) SetTenantPolicy(policy *TenantPolicy) *Builder {
+	b.tenant = policy
+	return b
+}
+
+// SetPermissionPolicy restricts columns readable and writable by role from context, nil disables it
+func (b *Builder) SetPermissionPolicy(policy PermissionPolicy) *Builder {
+	b.perms = newPermissions(b.DbSchema, policy)
+	b.plans.reset()
+	return b
+}
+
+// SoftDelete marks rows of relation as deleted by setting timestamp column instead of deleting them,
+// queries and updates on relation skip soft deleted rows unless WithDeleted or OnlyDeleted is used, empty column disables it
+func (b *Builder) SoftDelete(relation string, column string) *Builder {
+	sch, rel := b.resolveNames(relation)
+	b.softDelete[sch+"."+rel] = column
+	return b
+}
+
+// softDeleteColumn returns soft delete column of relation, unknown relations fail when statement is built
+func (b *Builder) softDeleteColumn(relation string) string {
+	sch, rel, err := b.lookup(relation)
+	if err != nil {
+		return ""
+	}
+
+	return b.softDelete[sch+"."+rel]
+}
+
+// VersionColumn enables optimistic concurrency on relation, column is an integer column or XminVersion.
+// Update statements compare version sent with values and increment it, ErrConflict is returned when no row matches,
+// empty column disables it
+func (b *Builder) VersionColumn(relation string, column string) *Builder {
+	sch, rel := b.resolveNames(relation)
+	b.versions[sch+"."+rel] = column
+	b.plans.reset()
+	return b
+}
+
+func (b *Builder) versionColumn(relation string) string {
+	sch, rel := b.resolveNames(relation)
+	return b.versions[sch+"."+rel]
+}
+
+// Query selects from exposed resource, or from relation when nothing is exposed
 func (b *Builder) Query(target string) *QueryStatement {
+	relation, err := b.Resource(target, OpSelect)
+	if err != nil {
+		relation = target
+	}
+	q := b.query(relation)
+	q.err = err
+	return q
+}
+
+func (b *Builder) query(target string) *QueryStatement {
 	p := &params{}
 	q := &QueryStatement{
 		builder: b,
 		schema:  b.DbSchema,
 		target:  target,
+		withClause: &withClause{
+			schema: b.DbSchema,
+			params: p,
+		},
 		whereClause: &whereClause{
 			schema:        b.DbSchema,
 			target:        target,
+			softDelete:    b.softDeleteColumn(target),
+			searchConfig:  b.searchConfig,
+			tenant:        b.tenant,
+			perms:         b.perms,
 			statementArgs: make([]interface{}, 0),
 			values:        make(map[string]interface{}),
 			filter:        make(map[string]interface{}),
@@ -41,7 +152,40 @@ func (b *Builder) Query(target string) *QueryStatement {
 	return q
 }
 
+// Call calls exposed function, or function by name when nothing is exposed,
+// args map keys are argument names or their json case
+func (b *Builder) Call(function string, args map[string]interface{}) *CallStatement {
+	name, err := b.Resource(function, OpCall)
+	if err != nil {
+		name = function
+	}
+	c := b.call(name, args)
+	c.err = err
+	return c
+}
+
+func (b *Builder) call(function string, args map[string]interface{}) *CallStatement {
+	return &CallStatement{
+		builder:  b,
+		schema:   b.DbSchema,
+		function: function,
+		args:     args,
+		params:   &params{},
+	}
+}
+
+// Insert inserts into exposed resource, or relation when nothing is exposed
 func (b *Builder) Insert(target string) *InsertStatement {
+	relation, err := b.Resource(target, OpInsert)
+	if err != nil {
+		relation = target
+	}
+	s := b.insert(relation)
+	s.err = err
+	return s
+}
+
+func (b *Builder) insert(target string) *InsertStatement {
 	return &InsertStatement{
 		builder: b,
 		schema:  b.DbSchema,
@@ -49,12 +193,24 @@ func (b *Builder) Insert(target string) *InsertStatement {
 		returningClause: &returningClause{
 			schema: b.DbSchema,
 			target: target,
+			perms:  b.perms,
 		},
 		params: &params{},
 	}
 }
 
+// Update updates exposed resource, or relation when nothing is exposed
 func (b *Builder) Update(target string) *UpdateStatement {
+	relation, err := b.Resource(target, OpUpdate)
+	if err != nil {
+		relation = target
+	}
+	s := b.update(relation)
+	s.err = err
+	return s
+}
+
+func (b *Builder) update(target string) *UpdateStatement {
 	p := &params{}
 	return &UpdateStatement{
 		builder: b,
@@ -62,43 +218,64 @@ func (b *Builder) Update(target string) *UpdateStatement {
 		target:  target,
 		values:  make(map[string]interface{}),
 		whereClause: &whereClause{
-			schema: b.DbSchema,
-			target: target,
-			values: make(map[string]interface{}),
-			filter: make(map[string]interface{}),
-			params: p,
+			schema:       b.DbSchema,
+			target:       target,
+			softDelete:   b.softDeleteColumn(target),
+			searchConfig: b.searchConfig,
+			tenant:       b.tenant,
+			perms:        b.perms,
+			values:       make(map[string]interface{}),
+			filter:       make(map[string]interface{}),
+			params:       p,
 		},
 		returningClause: &returningClause{
 			schema: b.DbSchema,
 			target: target,
+			perms:  b.perms,
 		},
 		params: p,
 	}
 }
 
+// Delete deletes from exposed resource, or relation when nothing is exposed
 func (b *Builder) Delete(target string) *DeleteStatement {
+	relation, err := b.Resource(target, OpDelete)
+	if err != nil {
+		relation = target
+	}
+	s := b.delete(relation)
+	s.err = err
+	return s
+}
+
+func (b *Builder) delete(target string) *DeleteStatement {
 	p := &params{}
 	return &DeleteStatement{
 		builder: b,
 		schema:  b.DbSchema,
 		target:  target,
 		whereClause: &whereClause{
-			schema: b.DbSchema,
-			target: target,
-			values: make(map[string]interface{}),
-			filter: make(map[string]interface{}),
-			params: p,
+			schema:       b.DbSchema,
+			target:       target,
+			softDelete:   b.softDeleteColumn(target),
+			searchConfig: b.searchConfig,
+			tenant:       b.tenant,
+			perms:        b.perms,
+			values:       make(map[string]interface{}),
+			filter:       make(map[string]interface{}),
+			params:       p,
 		},
 		returningClause: &returningClause{
 			schema: b.DbSchema,
 			target: target,
+			perms:  b.perms,
 		},
 		params: p,
 	}
 }
 
 func (b *Builder) Exec(conn PgxConn, ctx context.Context, sql string, args []interface{}) (string, error) {
-	ct, err := conn.Exec(ctx, sql, args...)
+	ct, err := b.exec(conn, ctx, sql, args)
 	if err != nil {
 		return "", err
 	}
@@ -106,6 +283,10 @@ func (b *Builder) Exec(conn PgxConn, ctx context.Context, sql string, args []int
 	return fmt.Sprintf("{\"rowsAffected\": %v}", ct.RowsAffected()), nil
 }
 
+func (b *Builder) exec(conn PgxConn, ctx context.Context, sql string, args []interface{}) (pgconn.CommandTag, error) {
+	return conn.Exec(ctx, sql, args...)
+}
+
 func (b *Builder) One(conn PgxConn, ctx context.Context, sql string, args []interface{}) (string, error) {
 	json := new(string)
 	err := conn.QueryRow(ctx, sql, args...).Scan(json)
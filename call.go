@@ -0,0 +1,97 @@
+package pgxjrep
+
+import (
+	"context"
+	"github.com/jackc/pgtype"
+	"strings"
+)
+
+// CallStatement calls function with named arguments bound from map
+type CallStatement struct {
+	builder  *Builder
+	schema   *DbSchema
+	function string
+	args     map[string]interface{}
+	fn       *FunctionSchema
+	params   *params
+	err      error
+}
+
+func (s *CallStatement) Build() (string, []interface{}) {
+	sql, args, err := s.BuildContext(context.Background())
+	if err != nil {
+		log.Panicln(err)
+	}
+
+	return sql, args
+}
+
+// BuildContext returns SELECT from function call, scalar results are in value column
+func (s *CallStatement) BuildContext(ctx context.Context) (string, []interface{}, error) {
+	call, err := s.call()
+	if err != nil {
+		return "", nil, err
+	}
+
+	if s.fn.ReturnsRecord {
+		return "SELECT * FROM " + call + " t", s.params.args, nil
+	}
+
+	return "SELECT t.value FROM " + call + " t(value)", s.params.args, nil
+}
+
+// call returns function call expression binding args in named notation, cast to argument types
+func (s *CallStatement) call() (string, error) {
+	if s.err != nil {
+		return "", s.err
+	}
+	fn, args, keys, err := s.schema.overload(s.function, s.args)
+	if err != nil {
+		return "", err
+	}
+	s.fn = fn
+
+	var exprs []string
+	for i, v := range args {
+		exprs = append(exprs, s.schema.Quote(v.Name)+" => "+s.params.get(s.args[keys[i]])+"::"+v.DataType)
+	}
+
+	return s.schema.Quote(fn.SchemaName) + "." + s.schema.Quote(fn.FunctionName) + "(" + strings.Join(exprs, ", ") + ")", nil
+}
+
+func (s *CallStatement) source(p *params) {
+	s.params = p
+}
+
+// Result returns function result as json, array for set-returning functions, null for void functions
+func (s *CallStatement) Result(conn PgxConn, ctx context.Context) (string, error) {
+	call, err := s.call()
+	if err != nil {
+		return "", err
+	}
+
+	var sql string
+	switch {
+	case s.fn.ReturnsVoid:
+		sql = "SELECT NULL::json FROM " + call + " t(value)"
+	case s.fn.ReturnsSet && s.fn.ReturnsRecord:
+		sql = "SELECT COALESCE(json_agg(t), '[]') FROM " + call + " t"
+	case s.fn.ReturnsSet:
+		sql = "SELECT COALESCE(json_agg(t.value), '[]') FROM " + call + " t(value)"
+	case s.fn.ReturnsRecord:
+		sql = "SELECT row_to_json(t) FROM " + call + " t"
+	default:
+		sql = "SELECT to_json(t.value) FROM " + call + " t(value)"
+	}
+
+	json := new(pgtype.Text)
+	err = conn.QueryRow(ctx, sql, s.params.args...).Scan(json)
+	if err != nil {
+		return "", err
+	}
+	if json.Status == pgtype.Null {
+		return "null", nil
+	}
+
+	return json.String, nil
+}
@@ -0,0 +1,70 @@
+package pgxjrep_test
+
+import (
+	"context"
+	"github.com/divilla/pgxjrep"
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestCallBuild(t *testing.T) {
+	Init(t)
+
+	fns := builder.FunctionSchema("add_numbers")
+	assert.Equal(t, 1, len(fns))
+	assert.Equal(t, pgxjrep.VolatilityImmutable, fns[0].Volatility)
+	assert.Equal(t, []pgxjrep.FunctionArg{
+		{Name: "first_number", DataType: "integer"},
+		{Name: "second_number", DataType: "integer", HasDefault: true},
+	}, fns[0].Args)
+
+	stm, argsOut := builder.Call("add_numbers", map[string]interface{}{"firstNumber": 1, "second_number": 2}).Build()
+	assert.Equal(t, "SELECT t.value FROM public.add_numbers(first_number => $1::integer, second_number => $2::integer) t(value)", stm)
+	assert.Equal(t, append(args, 1, 2), argsOut)
+
+	stm, argsOut = builder.Call("add_numbers", map[string]interface{}{"firstNumber": 1}).Build()
+	assert.Equal(t, "SELECT t.value FROM public.add_numbers(first_number => $1::integer) t(value)", stm)
+	assert.Equal(t, append(args, 1), argsOut)
+
+	stm, _ = builder.Call("number_labels", map[string]interface{}{"labelPrefix": "a", "labelCount": 2}).Build()
+	assert.Equal(t, "SELECT * FROM public.number_labels(label_prefix => $1::text, label_count => $2::integer) t", stm)
+
+	_, _, err := builder.Call("add_numbers", map[string]interface{}{"secondNumber": 1}).BuildContext(ctx)
+	assert.EqualError(t, err, "function public.add_numbers has no overload matching given arguments")
+}
+
+// noFunctionsConn hides all functions from schema loading
+type noFunctionsConn struct {
+	*pgx.Conn
+}
+
+func (c noFunctionsConn) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return c.Conn.QueryRow(ctx, strings.Replace(sql, "has_function_privilege(p.oid, 'EXECUTE')", "false", 1), args...)
+}
+
+func TestCallNoFunctions(t *testing.T) {
+	Init(t)
+
+	b, err := pgxjrep.NewBuilder(noFunctionsConn{conn}, ctx)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(b.FunctionSchema("add_numbers")))
+}
+
+func TestCallExec(t *testing.T) {
+	Init(t)
+
+	json, err := builder.Call("add_numbers", map[string]interface{}{"firstNumber": 1, "secondNumber": 2}).Result(conn, ctx)
+	assert.Equal(t, "3", json)
+	assert.Equal(t, nil, err)
+
+	repo := pgxjrep.New(builder, conn, ctx)
+	json, err = repo.Rpc("number_labels", map[string]interface{}{"labelPrefix": "a", "labelCount": 2})
+	assert.Equal(t, "[{\"id\":1,\"label\":\"a1\"}, \n {\"id\":2,\"label\":\"a2\"}]", json)
+	assert.Equal(t, nil, err)
+
+	json, err = repo.Rpc("number_labels", map[string]interface{}{"labelPrefix": "a", "labelCount": 0})
+	assert.Equal(t, "[]", json)
+	assert.Equal(t, nil, err)
+}
@@ -2,15 +2,43 @@ package pgxjrep
 
 import (
 	"context"
+	"fmt"
+	"strings"
 )
 
 type DeleteStatement struct {
 	builder         *Builder
 	schema          *DbSchema
 	target          string
+	using           []string
+	hardDelete      bool
+	restore         bool
 	whereClause     *whereClause
 	returningClause *returningClause
 	params          *params
+	err             error
+}
+
+// Using joins relations, where map keys reference their columns as "relation.column"
+func (s *DeleteStatement) Using(relations ...string) *DeleteStatement {
+	s.using = relations
+	s.whereClause.relations = relations
+	s.returningClause.qualified = len(relations) > 0
+	return s
+}
+
+// HardDelete deletes rows of soft delete relation
+func (s *DeleteStatement) HardDelete() *DeleteStatement {
+	s.hardDelete = true
+	s.whereClause.scope = scopeWithDeleted
+	return s
+}
+
+// Restore reverts soft delete of matching rows
+func (s *DeleteStatement) Restore() *DeleteStatement {
+	s.restore = true
+	s.whereClause.scope = scopeOnlyDeleted
+	return s
 }
 
 func (s *DeleteStatement) WhereStatement(statement string, args ...interface{}) *DeleteStatement {
@@ -30,25 +58,89 @@ func (s *DeleteStatement) Returning(cols ...string) *DeleteStatement {
 }
 
 func (s *DeleteStatement) Build() (string, []interface{}) {
-	var q = "DELETE FROM "
-	q += s.schema.QuoteRelation(s.target)
-	q += s.whereClause.build()
-	q += s.returningClause.build()
+	sql, args, err := s.BuildContext(context.Background())
+	if err != nil {
+		log.Panicln(err)
+	}
+
+	return sql, args
+}
+
+func (s *DeleteStatement) BuildContext(ctx context.Context) (sql string, args []interface{}, err error) {
+	defer recoverBuildError(&err)
+
+	if s.err != nil {
+		return "", nil, s.err
+	}
+
+	var q string
+	softDelete := s.whereClause.softDelete
+	if s.restore && softDelete == "" {
+		return "", nil, fmt.Errorf("relation %s is not configured for soft delete", s.target)
+	}
+
+	if softDelete != "" && !s.hardDelete {
+		s.schema.checkWritable(s.target, OpUpdate)
+		q = "UPDATE " + s.schema.QuoteRelation(s.target) + " SET " + s.schema.Quote(softDelete)
+		if s.restore {
+			q += " = NULL"
+		} else {
+			q += " = now()"
+		}
+
+		auditCols, auditVals := s.builder.audit.assignments(ctx, s.schema, s.target, false, s.params)
+		for i, v := range auditCols {
+			q += ", " + v + " = " + auditVals[i]
+		}
+	} else {
+		s.schema.checkWritable(s.target, OpDelete)
+		q = "DELETE FROM " + s.schema.QuoteRelation(s.target)
+	}
+
+	if len(s.using) > 0 {
+		var rels []string
+		for _, v := range s.using {
+			rels = append(rels, s.schema.QuoteRelation(v))
+		}
+		if softDelete != "" && !s.hardDelete {
+			q += " FROM " + strings.Join(rels, ", ")
+		} else {
+			q += " USING " + strings.Join(rels, ", ")
+		}
+	}
+
+	q += s.whereClause.build(ctx)
+	q += s.returningClause.build(ctx)
+
+	return q, s.params.args, nil
+}
 
-	return q, s.params.args
+func (s *DeleteStatement) source(p *params) {
+	s.params = p
+	s.whereClause.params = p
+	s.returningClause.nested = true
 }
 
 func (s *DeleteStatement) Exec(conn PgxConn, ctx context.Context) (string, error) {
-	sql, args := s.Build()
+	sql, args, err := s.BuildContext(ctx)
+	if err != nil {
+		return "", err
+	}
 	return s.builder.Exec(conn, ctx, sql, args)
 }
 
 func (s *DeleteStatement) One(conn PgxConn, ctx context.Context) (string, error) {
-	sql, args := s.Build()
+	sql, args, err := s.BuildContext(ctx)
+	if err != nil {
+		return "", err
+	}
 	return s.builder.One(conn, ctx, sql, args)
 }
 
 func (s *DeleteStatement) OneMap(conn PgxConn, ctx context.Context) (map[string]interface{}, error) {
-	sql, args := s.Build()
+	sql, args, err := s.BuildContext(ctx)
+	if err != nil {
+		return nil, err
+	}
 	return s.builder.OneMap(conn, ctx, sql, args)
 }
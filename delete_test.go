@@ -29,6 +29,12 @@ func TestDeleteBuild(t *testing.T) {
 		{str: builder.Delete("test.Test2").Where(pk1),
 			stm:  "DELETE FROM test.\"Test2\" WHERE \"Id\" = $1",
 			args: append(args, 11)},
+		{str: builder.Delete("test1").Using("test.Test2").Where(map[string]interface{}{
+			"b_B":          pgxjrep.Column("test.Test2.Id"),
+			"test.Test2.X": "inactive",
+		}),
+			stm:  "DELETE FROM test1 USING test.\"Test2\" WHERE test1.\"b_B\" = test.\"Test2\".\"Id\" AND test.\"Test2\".\"X\" LIKE $1",
+			args: append(args, "inactive")},
 	}
 
 	for _, v := range buildResults {
@@ -78,3 +84,37 @@ func TestDeleteExec(t *testing.T) {
 	assert.Equal(t, "{\"id\":3,\"aA\":\"a\",\"bB\":1,\"ccCc\":true}", json)
 	assert.Equal(t, nil, err)
 }
+
+func TestDeleteSoft(t *testing.T) {
+	Init(t)
+	builder.SoftDelete("test_soft", "deleted_at")
+	defer builder.SoftDelete("test_soft", "")
+
+	buildResults := []deleteBuild{
+		{str: builder.Delete("test_soft").Where(pk1).Returning("id"),
+			stm:  "UPDATE test_soft SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL RETURNING json_build_object('id', id)",
+			args: append(args, 11)},
+		{str: builder.Delete("test_soft").Restore().Where(pk1),
+			stm:  "UPDATE test_soft SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL",
+			args: append(args, 11)},
+		{str: builder.Delete("test_soft").HardDelete().Where(pk1),
+			stm:  "DELETE FROM test_soft WHERE id = $1",
+			args: append(args, 11)},
+	}
+
+	for _, v := range buildResults {
+		stm, argsOut := v.str.Build()
+		assert.Equal(t, v.stm, stm)
+		assert.Equal(t, v.args, argsOut)
+	}
+
+	stm, _ := builder.Query("test_soft").Build()
+	assert.Equal(t, "SELECT id, name, deleted_at AS \"deletedAt\" FROM test_soft WHERE deleted_at IS NULL", stm)
+	stm, _ = builder.Query("test_soft").WithDeleted().Build()
+	assert.Equal(t, "SELECT id, name, deleted_at AS \"deletedAt\" FROM test_soft", stm)
+	stm, _ = builder.Query("test_soft").OnlyDeleted().WhereStatement("id = ? OR name = ?", 1, "a").Build()
+	assert.Equal(t, "SELECT id, name, deleted_at AS \"deletedAt\" FROM test_soft WHERE (id = $1 OR name = $2) AND deleted_at IS NOT NULL", stm)
+
+	_, _, err := builder.Delete("test1").Restore().BuildContext(ctx)
+	assert.NotEqual(t, nil, err)
+}
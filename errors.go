@@ -0,0 +1,20 @@
+package pgxjrep
+
+// buildError carries errors raised deep inside clause building up to BuildContext
+type buildError struct {
+	err error
+}
+
+func throw(err error) {
+	panic(buildError{err: err})
+}
+
+func recoverBuildError(err *error) {
+	if r := recover(); r != nil {
+		be, ok := r.(buildError)
+		if !ok {
+			panic(r)
+		}
+		*err = be.err
+	}
+}
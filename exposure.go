@@ -0,0 +1,93 @@
+package pgxjrep
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Operation is a set of statement kinds allowed on exposed resource
+type Operation uint8
+
+const (
+	OpSelect Operation = 1 << iota
+	OpInsert
+	OpUpdate
+	OpDelete
+	OpCall
+	OpAll = OpSelect | OpInsert | OpUpdate | OpDelete
+)
+
+var ErrNotExposed = errors.New("resource is not exposed")
+
+func (o Operation) String() string {
+	var names []string
+	for _, v := range []struct {
+		op   Operation
+		name string
+	}{{OpSelect, "select"}, {OpInsert, "insert"}, {OpUpdate, "update"}, {OpDelete, "delete"}, {OpCall, "call"}} {
+		if o&v.op != 0 {
+			names = append(names, v.name)
+		}
+	}
+
+	return strings.Join(names, "|")
+}
+
+// OperationError is returned when operation is not allowed on exposed resource
+type OperationError struct {
+	Resource  string
+	Operation Operation
+}
+
+func (e *OperationError) Error() string {
+	return fmt.Sprintf("operation %s is not allowed on resource %s", e.Operation, e.Resource)
+}
+
+type exposure struct {
+	relation string
+	ops      Operation
+}
+
+// Expose publishes relation under resource name with allowed operations, all but OpCall when none are given.
+// Once any relation is exposed, Builder statements and Repository accept resource names only
+func (b *Builder) Expose(resource string, relation string, ops ...Operation) *Builder {
+	sch, rel := b.resolveNames(relation)
+	e := exposure{relation: sch + "." + rel}
+	for _, v := range ops {
+		e.ops |= v
+	}
+	if len(ops) == 0 {
+		e.ops = OpAll
+	}
+	b.resources[resource] = e
+	return b
+}
+
+// ExposeFunction publishes function under resource name, to be called by Repository.Rpc
+func (b *Builder) ExposeFunction(resource string, function string) *Builder {
+	name, err := b.ResolveFunction(function)
+	if err != nil {
+		log.Panicln(err)
+	}
+	b.resources[resource] = exposure{relation: name, ops: OpCall}
+	return b
+}
+
+// Resource returns relation exposed under resource name if operation is allowed on it,
+// resource is returned as relation name when nothing is exposed
+func (b *Builder) Resource(resource string, op Operation) (string, error) {
+	if len(b.resources) == 0 {
+		return resource, nil
+	}
+
+	e, ok := b.resources[resource]
+	if !ok {
+		return "", ErrNotExposed
+	}
+	if e.ops&op != op {
+		return "", &OperationError{Resource: resource, Operation: op &^ e.ops}
+	}
+
+	return e.relation, nil
+}
@@ -0,0 +1,192 @@
+package pgxjrep
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	VolatilityImmutable = "i"
+	VolatilityStable    = "s"
+	VolatilityVolatile  = "v"
+)
+
+type FunctionSchema struct {
+	SchemaName    string        `json:"schemaName"`
+	FunctionName  string        `json:"functionName"`
+	Args          []FunctionArg `json:"args"`
+	Defaults      int           `json:"defaults"`
+	ReturnType    string        `json:"returnType"`
+	ReturnsSet    bool          `json:"returnsSet"`
+	ReturnsRecord bool          `json:"returnsRecord"`
+	ReturnsVoid   bool          `json:"returnsVoid"`
+	Volatility    string        `json:"volatility"`
+}
+
+// FunctionArg is input argument of function, unnamed arguments can not be bound from map
+type FunctionArg struct {
+	Name       string `json:"name"`
+	DataType   string `json:"dataType"`
+	HasDefault bool   `json:"-"`
+}
+
+func (s *DbSchema) loadFunctions(conn PgxConn, ctx context.Context) error {
+	sql := `
+		SELECT COALESCE(json_agg(t), '[]')::text
+			FROM (SELECT
+				n.nspname::text                                                    AS "schemaName",
+				p.proname::text                                                    AS "functionName",
+				(SELECT COALESCE(json_agg(json_build_object(
+						'name', COALESCE(a.name, ''),
+						'dataType', format_type(a.type, NULL)
+					) ORDER BY a.ord), '[]')
+					FROM unnest(
+						COALESCE(p.proallargtypes, p.proargtypes::oid[]),
+						COALESCE(p.proargmodes, array_fill('i'::"char", ARRAY[p.pronargs::int])),
+						p.proargnames
+					) WITH ORDINALITY AS a(type, mode, name, ord)
+					WHERE a.mode IN ('i', 'b', 'v'))                               AS "args",
+				p.pronargdefaults::int                                             AS "defaults",
+				format_type(p.prorettype, NULL)                                    AS "returnType",
+				p.proretset                                                        AS "returnsSet",
+				(rt.typtype = 'c' OR p.prorettype = 'record'::regtype)             AS "returnsRecord",
+				p.prorettype = 'void'::regtype                                     AS "returnsVoid",
+				p.provolatile::text                                                AS "volatility"
+			FROM
+				pg_proc p
+				JOIN pg_namespace n ON n.oid = p.pronamespace
+				JOIN pg_type rt ON rt.oid = p.prorettype
+			WHERE
+				p.prokind = 'f'
+				AND p.prorettype != 'trigger'::regtype
+				AND n.nspname NOT LIKE 'pg_%' AND n.nspname != 'information_schema'
+				AND has_function_privilege(p.oid, 'EXECUTE')
+			ORDER BY
+				n.nspname,
+				p.proname
+		) t
+`
+
+	jsn := new(string)
+	err := conn.QueryRow(ctx, sql).Scan(jsn)
+	if err != nil {
+		return err
+	}
+
+	var res []FunctionSchema
+	err = json.Unmarshal([]byte(*jsn), &res)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range res {
+		for i := len(v.Args) - v.Defaults; i < len(v.Args); i++ {
+			if i >= 0 {
+				v.Args[i].HasDefault = true
+			}
+		}
+		if _, ok := s.functions[v.SchemaName]; !ok {
+			s.functions[v.SchemaName] = make(map[string][]FunctionSchema)
+		}
+		s.functions[v.SchemaName][v.FunctionName] = append(s.functions[v.SchemaName][v.FunctionName], v)
+	}
+
+	return nil
+}
+
+// FunctionSchema returns overloads of function, unqualified name is looked up in search path
+func (s *DbSchema) FunctionSchema(function string) []FunctionSchema {
+	sch, fn, err := s.lookupFunction(function)
+	if err != nil {
+		return nil
+	}
+
+	return s.functions[sch][fn]
+}
+
+// ResolveFunction returns schema qualified name of function
+func (s *DbSchema) ResolveFunction(function string) (string, error) {
+	sch, fn, err := s.lookupFunction(function)
+	if err != nil {
+		return "", err
+	}
+
+	return sch + "." + fn, nil
+}
+
+func (s *DbSchema) lookupFunction(function string) (string, string, error) {
+	names := strings.Split(function, ".")
+
+	switch len(names) {
+	case 2:
+		if _, ok := s.functions[names[0]][names[1]]; !ok {
+			return "", "", fmt.Errorf("function not found: %s", function)
+		}
+		return names[0], names[1], nil
+	case 1:
+		for _, v := range s.searchPath {
+			if _, ok := s.functions[v][function]; ok {
+				return v, function, nil
+			}
+		}
+		return "", "", fmt.Errorf("function not found in search path %s: %s", strings.Join(s.searchPath, ", "), function)
+	}
+
+	return "", "", fmt.Errorf("invalid function name: %s", function)
+}
+
+// overload returns function overload accepting all args and having every argument without default among them,
+// with db names of args in order of function arguments
+func (s *DbSchema) overload(function string, args map[string]interface{}) (*FunctionSchema, []FunctionArg, []string, error) {
+	sch, fn, err := s.lookupFunction(function)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// map keys are db argument names or json names converted by ToDbCase
+	names := make(map[string]string, len(args))
+	for k := range args {
+		names[s.ToDbCase(k)] = k
+	}
+	for k := range args {
+		names[k] = k
+	}
+
+	var match *FunctionSchema
+	var matchArgs []FunctionArg
+	var matchKeys []string
+	for i, f := range s.functions[sch][fn] {
+		var bound []FunctionArg
+		var keys []string
+		ok := true
+		for _, a := range f.Args {
+			key, found := names[a.Name]
+			if !found || a.Name == "" {
+				if !a.HasDefault {
+					ok = false
+					break
+				}
+				continue
+			}
+			bound = append(bound, a)
+			keys = append(keys, key)
+		}
+		if !ok || len(keys) != len(args) {
+			continue
+		}
+		if match != nil {
+			return nil, nil, nil, fmt.Errorf("function %s.%s call is ambiguous for given arguments", sch, fn)
+		}
+		match = &s.functions[sch][fn][i]
+		matchArgs = bound
+		matchKeys = keys
+	}
+
+	if match == nil {
+		return nil, nil, nil, fmt.Errorf("function %s.%s has no overload matching given arguments", sch, fn)
+	}
+
+	return match, matchArgs, matchKeys, nil
+}
@@ -12,6 +12,7 @@ type InsertStatement struct {
 	values          map[string]interface{}
 	returningClause *returningClause
 	params          *params
+	err             error
 }
 
 func (s *InsertStatement) Values(m map[string]interface{}) *InsertStatement {
@@ -30,43 +31,93 @@ func (s *InsertStatement) Returning(cols ...string) *InsertStatement {
 }
 
 func (s *InsertStatement) Build() (string, []interface{}) {
+	sql, args, err := s.BuildContext(context.Background())
+	if err != nil {
+		log.Panicln(err)
+	}
+
+	return sql, args
+}
+
+func (s *InsertStatement) BuildContext(ctx context.Context) (sql string, args []interface{}, err error) {
+	defer recoverBuildError(&err)
+
+	if s.err != nil {
+		return "", nil, s.err
+	}
+
+	s.schema.checkWritable(s.target, OpInsert)
+
 	var q = "INSERT"
 
 	q += " INTO " + s.schema.QuoteRelation(s.target)
 
-	if len(s.values) > 0 {
-		var cols, vals []string
-
-		for _, v := range s.schema.ResolveColumnMap(s.target, s.values) {
-			if v.Value == nil {
-				continue
+	var cols, vals []string
+	colData := s.schema.ResolveColumnMap(s.target, s.values)
+	s.builder.perms.checkWrite(ctx, s.target, colData)
+	s.schema.validate(s.target, colData)
+	for _, v := range colData {
+		if v.Value == nil {
+			continue
+		} else if s.builder.audit.protected(v.DbName) {
+			log.Warningf("Audit column %s can not be set on relation %s", v.DbName, s.target)
+		} else if s.builder.tenant.scoped(s.schema, s.target) && v.DbName == s.builder.tenant.Column {
+			log.Warningf("Tenant column %s can not be set on relation %s", v.DbName, s.target)
+		} else {
+			cols = append(cols, s.schema.Quote(v.DbName))
+			if v.isRange() {
+				vals = append(vals, s.schema.rangeValue(v, v.Value, s.params))
 			} else {
-				cols = append(cols, s.schema.Quote(v.DbName))
 				vals = append(vals, s.params.get(v.Value))
 			}
 		}
+	}
+
+	if s.builder.tenant.scoped(s.schema, s.target) {
+		cols = append(cols, s.schema.Quote(s.builder.tenant.Column))
+		vals = append(vals, s.params.get(s.builder.tenant.tenant(ctx)))
+	}
 
+	auditCols, auditVals := s.builder.audit.assignments(ctx, s.schema, s.target, true, s.params)
+	cols = append(cols, auditCols...)
+	vals = append(vals, auditVals...)
+
+	if len(cols) > 0 {
 		q += " (" + strings.Join(cols, ", ") + ") VALUES (" + strings.Join(vals, ", ") + ")"
 	} else {
 		q += " DEFAULT VALUES"
 	}
 
-	q += s.returningClause.build()
+	q += s.returningClause.build(ctx)
 
-	return q, s.params.args
+	return q, s.params.args, nil
+}
+
+func (s *InsertStatement) source(p *params) {
+	s.params = p
+	s.returningClause.nested = true
 }
 
 func (s *InsertStatement) Exec(conn PgxConn, ctx context.Context) (string, error) {
-	sql, args := s.Build()
+	sql, args, err := s.BuildContext(ctx)
+	if err != nil {
+		return "", err
+	}
 	return s.builder.Exec(conn, ctx, sql, args)
 }
 
 func (s *InsertStatement) One(conn PgxConn, ctx context.Context) (string, error) {
-	sql, args := s.Build()
+	sql, args, err := s.BuildContext(ctx)
+	if err != nil {
+		return "", err
+	}
 	return s.builder.One(conn, ctx, sql, args)
 }
 
 func (s *InsertStatement) OneMap(conn PgxConn, ctx context.Context) (map[string]interface{}, error) {
-	sql, args := s.Build()
+	sql, args, err := s.BuildContext(ctx)
+	if err != nil {
+		return nil, err
+	}
 	return s.builder.OneMap(conn, ctx, sql, args)
 }
@@ -81,3 +81,28 @@ func TestInsertExec(t *testing.T) {
 	assert.Equal(t, jsonMap["ccCc"], true)
 	assert.Equal(t, nil, err)
 }
+
+func TestInsertAudit(t *testing.T) {
+	Init(t)
+	builder.SetAuditPolicy(pgxjrep.DefaultAuditPolicy())
+	defer builder.SetAuditPolicy(nil)
+
+	actorCtx := pgxjrep.ContextWithActor(ctx, "joe")
+
+	stm, argsOut, err := builder.Insert("test_audit").
+		Values(map[string]interface{}{"name": "a", "createdAt": "2000-01-01"}).
+		BuildContext(actorCtx)
+	assert.Equal(t, "INSERT INTO test_audit (name, created_at, updated_at, created_by, updated_by) VALUES ($1, now(), now(), $2, $3)", stm)
+	assert.Equal(t, append(args, "a", "joe", "joe"), argsOut)
+	assert.Equal(t, nil, err)
+
+	stm, argsOut, err = builder.Insert("test_audit").
+		Values(map[string]interface{}{"name": "a"}).
+		BuildContext(ctx)
+	assert.Equal(t, "INSERT INTO test_audit (name, created_at, updated_at) VALUES ($1, now(), now())", stm)
+	assert.Equal(t, append(args, "a"), argsOut)
+	assert.Equal(t, nil, err)
+
+	stm, _ = builder.Insert("test1").Values(insert1).Build()
+	assert.Equal(t, "INSERT INTO test1 (a_a, \"b_B\") VALUES ($1, $2)", stm)
+}
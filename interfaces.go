@@ -9,6 +9,14 @@ import (
 type Map map[string]interface{}
 type Strings []string
 
+// Statement is implemented by Query, Insert, Update and Delete statements,
+// it can be nested inside another statement sharing its parameter numbering
+type Statement interface {
+	Build() (string, []interface{})
+	BuildContext(ctx context.Context) (string, []interface{}, error)
+	source(p *params)
+}
+
 type PgxConn interface {
 	Exec(ctx context.Context, sql string, arguments ...interface{}) (commandTag pgconn.CommandTag, err error)
 	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
@@ -0,0 +1,309 @@
+package pgxjrep
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/jackc/pgtype"
+	"sort"
+	"strings"
+)
+
+func (v ColumnData) isJson() bool {
+	return v.class == ClassJson && v.dimension == 0
+}
+
+// jsonValue encodes where value compared to jsonb
+func jsonValue(value interface{}) string {
+	b, err := json.Marshal(value)
+	if err != nil {
+		throw(err)
+	}
+
+	return string(b)
+}
+
+// jsonColumn returns jsonb expression of column or its path
+func (c *whereClause) jsonColumn(v ColumnData) string {
+	col := c.column(v)
+	if v.typeOid == pgtype.JSONOID {
+		col += "::jsonb"
+	}
+	if len(v.path) > 0 {
+		return "(" + col + " #> " + c.params.get(v.path) + "::text[])"
+	}
+
+	return col
+}
+
+// jsonExpr compares json column or its path with where value, strings are compared to text of path
+func (c *whereClause) jsonExpr(ctx context.Context, v ColumnData) string {
+	if ops, ok := operatorMap(v.Value); ok {
+		return c.operators(ctx, v, ops)
+	}
+
+	switch val := v.Value.(type) {
+	case nil:
+		return c.jsonColumn(v) + " IS NULL"
+	case map[string]interface{}:
+		return c.jsonColumn(v) + " @> " + c.params.get(jsonValue(val)) + "::jsonb"
+	case string:
+		if len(v.path) > 0 {
+			return c.column(v) + " #>> " + c.params.get(v.path) + "::text[] = " + c.params.get(val)
+		}
+	}
+
+	return c.jsonColumn(v) + " = " + c.params.get(jsonValue(v.Value)) + "::jsonb"
+}
+
+// jsonPath splits "column.key.key" into json column and path, ok is false when name is not a path of json column
+func (s *DbSchema) jsonPath(relation string, name string) (ColumnData, []string, bool) {
+	segs := strings.Split(name, ".")
+	if len(segs) < 2 {
+		return ColumnData{}, nil, false
+	}
+	if _, ok := s.ColMap(relation)[segs[0]]; !ok {
+		return ColumnData{}, nil, false
+	}
+
+	cols := s.ResolveColumns(relation, segs[:1])
+	if len(cols) == 0 || !cols[0].isJson() {
+		return ColumnData{}, nil, false
+	}
+
+	return cols[0], segs[1:], true
+}
+
+// pathLiteral returns text array literal of json path, used where path can not be bound as param
+func (s *DbSchema) pathLiteral(path []string) string {
+	var elems []string
+	for _, v := range path {
+		v = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `'`, `''`).Replace(v)
+		elems = append(elems, `"`+v+`"`)
+	}
+
+	return "'{" + strings.Join(elems, ",") + "}'"
+}
+
+// jsonPathValues splits "column.key.key" values of json columns from column values, grouped by column
+func (s *DbSchema) jsonPathValues(relation string, m map[string]interface{}) (map[string]interface{}, map[string][]ColumnData) {
+	var paths map[string][]ColumnData
+	values := m
+	for k, v := range m {
+		col, path, ok := s.jsonPath(relation, k)
+		if !ok {
+			continue
+		}
+		if paths == nil {
+			paths = make(map[string][]ColumnData)
+			values = make(map[string]interface{}, len(m))
+			for k, v := range m {
+				values[k] = v
+			}
+		}
+		col.path = path
+		col.Value = v
+		paths[col.DbName] = append(paths[col.DbName], col)
+		delete(values, k)
+	}
+
+	return values, paths
+}
+
+// jsonAssignment updates json column partially: $merge merges keys recursively removing those with null value,
+// $set and "column.key" paths set values at paths creating missing objects, $remove removes paths, applied in that order
+func (s *DbSchema) jsonAssignment(col ColumnData, ops map[string]interface{}, paths []ColumnData, prm *params) string {
+	var sets []jsonPathValue
+	for _, v := range paths {
+		sets = append(sets, jsonPathValue{path: v.path, value: v.Value})
+	}
+
+	expr := s.Quote(col.DbName)
+	if col.typeOid == pgtype.JSONOID {
+		expr += "::jsonb"
+	}
+	expr = "COALESCE(" + expr + ", '{}'::jsonb)"
+
+	for k := range ops {
+		if k != MergeKey && k != SetKey && k != RemoveKey {
+			throw(fmt.Errorf("update operator %s is not supported on column %s of type %s", k, col.JsonName, col.dataType))
+		}
+	}
+
+	if merge, ok := ops[MergeKey]; ok {
+		patch, ok := merge.(map[string]interface{})
+		if !ok {
+			throw(fmt.Errorf("%s value of column %s must be an object", MergeKey, col.JsonName))
+		}
+		expr = jsonMerge(expr, patch, prm)
+	}
+
+	if set, ok := ops[SetKey]; ok {
+		m, ok := set.(map[string]interface{})
+		if !ok {
+			throw(fmt.Errorf("%s value of column %s must be an object", SetKey, col.JsonName))
+		}
+		for k, v := range m {
+			sets = append(sets, jsonPathValue{path: strings.Split(k, "."), value: v})
+		}
+	}
+	if len(sets) > 0 {
+		var objects [][]string
+		for _, v := range sets {
+			for i := 1; i < len(v.path); i++ {
+				objects = append(objects, v.path[:i])
+			}
+		}
+		expr = jsonSet(expr, objects, false, sets, prm)
+	}
+
+	if remove, ok := ops[RemoveKey]; ok {
+		for _, v := range stringList(remove) {
+			expr = "(" + expr + " #- " + prm.get(strings.Split(v, ".")) + "::text[])"
+		}
+	}
+
+	if col.typeOid == pgtype.JSONOID {
+		expr += "::json"
+	}
+
+	return s.Quote(col.DbName) + " = " + expr
+}
+
+// jsonPathValue is value set at path of json column
+type jsonPathValue struct {
+	path  []string
+	value interface{}
+}
+
+// jsonMerge merges patch into expr as json merge patch: top level values are concatenated,
+// nested objects are merged into existing objects key by key and null values remove keys
+func jsonMerge(expr string, patch map[string]interface{}, prm *params) string {
+	values := make(map[string]interface{}, len(patch))
+	var nulls []string
+	var objects [][]string
+	var sets []jsonPathValue
+	var removes [][]string
+	for k, v := range patch {
+		switch val := v.(type) {
+		case nil:
+			nulls = append(nulls, k)
+		case map[string]interface{}:
+			o, s, r := mergePaths([]string{k}, val)
+			objects = append(objects, o...)
+			sets = append(sets, s...)
+			removes = append(removes, r...)
+		default:
+			values[k] = v
+		}
+	}
+
+	if len(values) > 0 || len(objects) == 0 {
+		expr = "(" + expr + " || " + prm.get(jsonValue(values)) + "::jsonb)"
+	}
+	if len(nulls) > 0 {
+		sort.Strings(nulls)
+		expr = "(" + expr + " - " + prm.get(nulls) + "::text[])"
+	}
+	if len(objects) == 0 {
+		return expr
+	}
+
+	expr = jsonSet(expr, objects, true, sets, prm)
+	sortPaths(removes)
+	for _, v := range removes {
+		expr = "(" + expr + " #- " + prm.get(v) + "::text[])"
+	}
+
+	return expr
+}
+
+// mergePaths flattens nested merge patch at path into objects to be created, values to be set and paths to be removed
+func mergePaths(path []string, patch map[string]interface{}) ([][]string, []jsonPathValue, [][]string) {
+	objects := [][]string{path}
+	var sets []jsonPathValue
+	var removes [][]string
+	for k, v := range patch {
+		p := append(append([]string{}, path...), k)
+		switch val := v.(type) {
+		case nil:
+			removes = append(removes, p)
+		case map[string]interface{}:
+			o, s, r := mergePaths(p, val)
+			objects = append(objects, o...)
+			sets = append(sets, s...)
+			removes = append(removes, r...)
+		default:
+			sets = append(sets, jsonPathValue{path: p, value: v})
+		}
+	}
+
+	return objects, sets, removes
+}
+
+// jsonSet sets values at paths of expr, objects are created at paths first where missing,
+// replacing values that are not objects, or not containers unless objectsOnly is false
+func jsonSet(expr string, objects [][]string, objectsOnly bool, sets []jsonPathValue, prm *params) string {
+	sort.Slice(sets, func(i, j int) bool {
+		return strings.Join(sets[i].path, "\x00") < strings.Join(sets[j].path, "\x00")
+	})
+	if len(objects) == 0 {
+		for _, v := range sets {
+			expr = "jsonb_set(" + expr + ", " + prm.get(v.path) + "::text[], " + prm.get(jsonValue(v.value)) + "::jsonb, true)"
+		}
+		return expr
+	}
+
+	// existing values are read from j, value of expr, so created objects do not repeat expr
+	types := "= 'object'"
+	if !objectsOnly {
+		types = "IN ('object', 'array')"
+	}
+	set := "j"
+	sortPaths(objects)
+	created := make(map[string]bool)
+	for _, v := range objects {
+		key := strings.Join(v, "\x00")
+		if created[key] {
+			continue
+		}
+		created[key] = true
+		path := prm.get(v) + "::text[]"
+		set = "jsonb_set(" + set + ", " + path + ", CASE WHEN jsonb_typeof(j #> " + path + ") " + types +
+			" THEN j #> " + path + " ELSE '{}'::jsonb END, true)"
+	}
+	for _, v := range sets {
+		set = "jsonb_set(" + set + ", " + prm.get(v.path) + "::text[], " + prm.get(jsonValue(v.value)) + "::jsonb, true)"
+	}
+
+	return "(SELECT " + set + " FROM (SELECT " + expr + " AS j) t)"
+}
+
+// sortPaths sorts paths parents first
+func sortPaths(paths [][]string) {
+	sort.Slice(paths, func(i, j int) bool {
+		if len(paths[i]) != len(paths[j]) {
+			return len(paths[i]) < len(paths[j])
+		}
+		return strings.Join(paths[i], "\x00") < strings.Join(paths[j], "\x00")
+	})
+}
+
+// stringList returns strings of []string or []interface{} value decoded from json
+func stringList(value interface{}) []string {
+	switch val := value.(type) {
+	case string:
+		return []string{val}
+	case []string:
+		return val
+	case []interface{}:
+		var list []string
+		for _, v := range val {
+			list = append(list, fmt.Sprint(v))
+		}
+		return list
+	}
+
+	throw(fmt.Errorf("expected list of strings, got %T", value))
+	return nil
+}
@@ -0,0 +1,83 @@
+package pgxjrep
+
+import "strings"
+
+// MatchMode selects how values of string columns are compared in Where and Filter,
+// LIKE wildcards in values are always escaped and match literally
+type MatchMode int
+
+const (
+	// MatchDefault matches whole value with LIKE in Where and value prefix with ILIKE in Filter
+	MatchDefault MatchMode = iota
+	// MatchExact compares with =
+	MatchExact
+	// MatchLike matches case-sensitively, whole value in Where and value prefix in Filter
+	MatchLike
+	// MatchILike matches case-insensitively, whole value in Where and value prefix in Filter
+	MatchILike
+)
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// Match sets comparison of string column values
+func (s *QueryStatement) Match(mode MatchMode) *QueryStatement {
+	s.whereClause.match = mode
+	return s
+}
+
+// Collate compares string column values using collation, e.g. "de-x-icu"
+func (s *QueryStatement) Collate(collation string) *QueryStatement {
+	s.whereClause.collation = collation
+	return s
+}
+
+// Match sets comparison of string column values
+func (s *UpdateStatement) Match(mode MatchMode) *UpdateStatement {
+	s.whereClause.match = mode
+	return s
+}
+
+// Collate compares string column values using collation, e.g. "de-x-icu"
+func (s *UpdateStatement) Collate(collation string) *UpdateStatement {
+	s.whereClause.collation = collation
+	return s
+}
+
+// Match sets comparison of string column values
+func (s *DeleteStatement) Match(mode MatchMode) *DeleteStatement {
+	s.whereClause.match = mode
+	return s
+}
+
+// Collate compares string column values using collation, e.g. "de-x-icu"
+func (s *DeleteStatement) Collate(collation string) *DeleteStatement {
+	s.whereClause.collation = collation
+	return s
+}
+
+func (c *whereClause) stringExpr(col string, value interface{}, filter bool) string {
+	if c.collation != "" {
+		col += " COLLATE \"" + strings.Replace(c.collation, "\"", "\"\"", -1) + "\""
+	}
+
+	mode := c.match
+	if mode == MatchDefault && filter {
+		mode = MatchILike
+	} else if mode == MatchDefault {
+		mode = MatchLike
+	}
+	if mode == MatchExact {
+		return col + " = " + c.params.get(value)
+	}
+
+	if s, ok := value.(string); ok && filter {
+		value = likeEscaper.Replace(s) + "%"
+	} else if ok {
+		value = likeEscaper.Replace(s)
+	}
+	if mode == MatchILike {
+		return col + " ILIKE " + c.params.get(value)
+	}
+
+	return col + " LIKE " + c.params.get(value)
+}
@@ -1,5 +1,9 @@
 create schema if not exists test;
 
+create extension if not exists pg_trgm;
+
+create extension if not exists citext;
+
 create table if not exists test1
 (
     id serial not null
@@ -19,3 +23,141 @@ create table if not exists test."Test2"
     "Y" integer not null,
     "Z" boolean default true not null
 );
+
+create table if not exists test_audit
+(
+    id serial not null
+        constraint test_audit_pk
+            primary key,
+    name text not null,
+    created_at timestamptz not null,
+    updated_at timestamptz not null,
+    created_by text,
+    updated_by text
+);
+
+create table if not exists test_soft
+(
+    id serial not null
+        constraint test_soft_pk
+            primary key,
+    name text not null,
+    deleted_at timestamptz
+);
+
+create table if not exists test_version
+(
+    id serial not null
+        constraint test_version_pk
+            primary key,
+    name text not null,
+    version integer default 1 not null
+);
+
+create table if not exists test_tenant
+(
+    id serial not null
+        constraint test_tenant_pk
+            primary key,
+    tenant_id integer not null,
+    name text not null
+);
+
+create table if not exists test_annotated
+(
+    id serial not null
+        constraint test_annotated_pk
+            primary key,
+    is_active boolean default true not null,
+    email text,
+    secret text,
+    code text
+);
+
+comment on column test_annotated.is_active is 'Row is visible to users @json:active';
+comment on column test_annotated.email is '@format:email';
+comment on column test_annotated.secret is '@hidden';
+comment on column test_annotated.code is 'Assigned by trigger @readonly';
+
+create table if not exists test.test1
+(
+    id serial not null
+        constraint test_test1_pk
+            primary key
+);
+
+create or replace function add_numbers(first_number integer, second_number integer default 1)
+    returns integer
+    language sql
+    immutable
+as $$ select first_number + second_number $$;
+
+create or replace function number_labels(label_prefix text, label_count integer)
+    returns table (id integer, label text)
+    language sql
+    stable
+as $$ select i, label_prefix || i from generate_series(1, label_count) i $$;
+
+create materialized view if not exists test_matview as
+    select id, name from test_soft;
+
+create unique index if not exists test_matview_id_uindex on test_matview (id);
+
+create table if not exists test_jsonb
+(
+    id serial not null
+        constraint test_jsonb_pk
+            primary key,
+    meta jsonb not null default '{}',
+    doc json
+);
+
+create table if not exists test_array
+(
+    id serial not null
+        constraint test_array_pk
+            primary key,
+    tags text[] not null default '{}',
+    scores integer[]
+);
+
+create table if not exists test_range
+(
+    id serial not null
+        constraint test_range_pk
+            primary key,
+    period tstzrange not null,
+    seats int4range
+);
+
+create table if not exists test_search
+(
+    id serial not null
+        constraint test_search_pk
+            primary key,
+    body text not null,
+    document tsvector
+);
+
+do $$ begin
+    create type test_status as enum ('draft', 'published');
+exception
+    when duplicate_object then null;
+end $$;
+
+do $$ begin
+    create domain test_code as varchar(10);
+exception
+    when duplicate_object then null;
+end $$;
+
+create table if not exists test_types
+(
+    id serial not null
+        constraint test_types_pk
+            primary key,
+    status test_status default 'draft' not null,
+    label character varying(50),
+    email citext,
+    code test_code
+);
@@ -0,0 +1,88 @@
+package pgxjrep
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// operator keys of where map values, e.g. {"meta": {"$hasKey": "address"}}
+const (
+	ContainsKey   = "$contains"
+	HasKeyKey     = "$hasKey"
+	HasAnyKeysKey = "$hasAnyKeys"
+	HasAllKeysKey = "$hasAllKeys"
+	JsonPathKey   = "$jsonPath"
+)
+
+// update operator keys of set map values, e.g. {"meta": {"$merge": {"theme": "dark"}}}
+const (
+	MergeKey  = "$merge"
+	SetKey    = "$set"
+	RemoveKey = "$remove"
+)
+
+// operatorMap returns where map value as operators when all its keys are operator keys
+func operatorMap(value interface{}) (map[string]interface{}, bool) {
+	m, ok := value.(map[string]interface{})
+	if !ok || len(m) == 0 {
+		return nil, false
+	}
+	for k := range m {
+		if !strings.HasPrefix(k, "$") {
+			return nil, false
+		}
+	}
+
+	return m, true
+}
+
+// operators returns conditions of operator map applied to column, in key order
+func (c *whereClause) operators(ctx context.Context, v ColumnData, ops map[string]interface{}) string {
+	keys := make([]string, 0, len(ops))
+	for k := range ops {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var exprs []string
+	for _, k := range keys {
+		exprs = append(exprs, c.operator(ctx, v, k, ops[k]))
+	}
+
+	return strings.Join(exprs, " AND ")
+}
+
+func (c *whereClause) operator(ctx context.Context, v ColumnData, op string, value interface{}) string {
+	if expr, ok := c.arrayOperator(v, op, value); ok {
+		return expr
+	}
+	if expr, ok := c.rangeOperator(v, op, value); ok {
+		return expr
+	}
+	if expr, ok := c.trigramOperator(v, op, value); ok {
+		return expr
+	}
+	if op == SearchKey {
+		config := c.searchConfig(v.relation)
+		return c.schema.searchVector(v, c.column(v), config) + " @@ " + c.schema.searchQuery(c.params.get(value), config)
+	}
+	if v.isJson() {
+		switch op {
+		case ContainsKey:
+			return c.jsonColumn(v) + " @> " + c.params.get(jsonValue(value)) + "::jsonb"
+		case HasKeyKey:
+			return c.jsonColumn(v) + " ? " + c.params.get(value)
+		case HasAnyKeysKey:
+			return c.jsonColumn(v) + " ?| " + c.params.get(value) + "::text[]"
+		case HasAllKeysKey:
+			return c.jsonColumn(v) + " ?& " + c.params.get(value) + "::text[]"
+		case JsonPathKey:
+			return "jsonb_path_exists(" + c.jsonColumn(v) + ", " + c.params.get(value) + "::jsonpath)"
+		}
+	}
+
+	throw(fmt.Errorf("operator %s is not supported on column %s of type %s", op, v.JsonName, v.dataType))
+	return ""
+}
@@ -1,6 +1,10 @@
 package pgxjrep
 
-import "strconv"
+import (
+	"github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
+)
 
 type params struct {
 	index uint64
@@ -14,6 +18,12 @@ func (p *params) get(val interface{}) string {
 	return "$" + strconv.FormatUint(p.index, 10)
 }
 
+// reset drops bound params, so statement can be built again
+func (p *params) reset() {
+	p.index = 0
+	p.args = nil
+}
+
 func (p *params) getStartsWith(val interface{}) string {
 	p.index++
 	p.args = append(p.args, val.(string)+"%")
@@ -34,3 +44,25 @@ func (p *params) getContains(val interface{}) string {
 
 	return "$" + strconv.FormatUint(p.index, 10)
 }
+
+// bind replaces question marks in statement with positional params
+func (p *params) bind(statement string, args []interface{}) string {
+	var argsLen = len(args)
+	var input = statement
+	var output string
+	var index = strings.Index(input, "?")
+	var i = 0
+	for index >= 0 {
+		if i < argsLen {
+			output += input[:index] + p.get(args[i])
+		}
+		input = input[index+1:]
+		index = strings.Index(input, "?")
+		i++
+	}
+	if i > argsLen {
+		logrus.Panicf("Question mark count (%v) does not match args count (%v).", i, argsLen)
+	}
+
+	return output + input
+}
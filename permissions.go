@@ -0,0 +1,159 @@
+package pgxjrep
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var ErrMissingRole = errors.New("context has no role")
+
+// ColumnPermissions lists columns role can read and write on relation, nil list allows all columns
+type ColumnPermissions struct {
+	Read  []string
+	Write []string
+}
+
+// PermissionPolicy maps role to relation to column permissions, relations not listed for role are not restricted
+type PermissionPolicy map[string]map[string]ColumnPermissions
+
+// PermissionError is returned when statement references column role is not allowed to read or write
+type PermissionError struct {
+	Relation string
+	Column   string
+	Write    bool
+}
+
+func (e *PermissionError) Error() string {
+	if e.Write {
+		return fmt.Sprintf("column %s of relation %s is not writable", e.Column, e.Relation)
+	}
+
+	return fmt.Sprintf("column %s of relation %s is not readable", e.Column, e.Relation)
+}
+
+type roleKey struct{}
+
+func ContextWithRole(ctx context.Context, role string) context.Context {
+	return context.WithValue(ctx, roleKey{}, role)
+}
+
+func RoleFromContext(ctx context.Context) (string, bool) {
+	role, ok := ctx.Value(roleKey{}).(string)
+	return role, ok
+}
+
+// permissions is PermissionPolicy resolved to schema.relation and db column names,
+// without policy only column annotations are enforced
+type permissions struct {
+	schema *DbSchema
+	roles  map[string]map[string]*relationAccess
+}
+
+type relationAccess struct {
+	read  map[string]bool
+	write map[string]bool
+}
+
+func newPermissions(schema *DbSchema, policy PermissionPolicy) *permissions {
+	p := &permissions{schema: schema}
+	if policy == nil {
+		return p
+	}
+
+	p.roles = make(map[string]map[string]*relationAccess)
+	columns := func(relation string, cols []string) map[string]bool {
+		if cols == nil {
+			return nil
+		}
+		m := make(map[string]bool)
+		for _, v := range schema.ResolveColumns(relation, cols) {
+			m[v.DbName] = true
+		}
+		return m
+	}
+
+	for role, relations := range policy {
+		p.roles[role] = make(map[string]*relationAccess)
+		for relation, perms := range relations {
+			sch, rel := schema.resolveNames(relation)
+			p.roles[role][sch+"."+rel] = &relationAccess{
+				read:  columns(relation, perms.Read),
+				write: columns(relation, perms.Write),
+			}
+		}
+	}
+
+	return p
+}
+
+// access returns column access of role from context on relation, nil when relation is not restricted
+func (p *permissions) access(ctx context.Context, relation string) *relationAccess {
+	if p.roles == nil {
+		return nil
+	}
+
+	role, ok := RoleFromContext(ctx)
+	if !ok {
+		throw(ErrMissingRole)
+	}
+
+	sch, rel := p.schema.resolveNames(relation)
+	return p.roles[role][sch+"."+rel]
+}
+
+func (a *relationAccess) readable(column string) bool {
+	return a == nil || a.read == nil || a.read[column]
+}
+
+func (a *relationAccess) writable(column string) bool {
+	return a == nil || a.write == nil || a.write[column]
+}
+
+// readColumns returns readable columns of relation when columns are omitted,
+// otherwise fails the build on first column that is not readable, hidden columns are never readable
+func (p *permissions) readColumns(ctx context.Context, relation string, columns []string) []string {
+	a := p.access(ctx, relation)
+	if len(columns) == 0 {
+		if a == nil || a.read == nil {
+			return columns
+		}
+		for _, v := range p.schema.ColSchema(relation) {
+			if a.read[v.ColumnName] && !v.IsHidden {
+				columns = append(columns, v.ColumnName)
+			}
+		}
+		if len(columns) == 0 {
+			throw(&PermissionError{Relation: relation, Column: "*"})
+		}
+		return columns
+	}
+
+	names := make([]string, 0, len(columns))
+	for _, v := range columns {
+		if col, _, ok := p.schema.jsonPath(relation, v); ok {
+			v = col.DbName
+		}
+		names = append(names, v)
+	}
+	p.checkRead(ctx, relation, p.schema.ResolveColumns(relation, names))
+	return columns
+}
+
+func (p *permissions) checkRead(ctx context.Context, relation string, cols []ColumnData) {
+	a := p.access(ctx, relation)
+	for _, v := range cols {
+		if !a.readable(v.DbName) || v.hidden {
+			throw(&PermissionError{Relation: relation, Column: v.JsonName})
+		}
+	}
+}
+
+func (p *permissions) checkWrite(ctx context.Context, relation string, cols []ColumnData) {
+	a := p.access(ctx, relation)
+	for _, v := range cols {
+		if !a.writable(v.DbName) || v.readonly {
+			throw(&PermissionError{Relation: relation, Column: v.JsonName, Write: true})
+		}
+	}
+}
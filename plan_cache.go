@@ -0,0 +1,176 @@
+package pgxjrep
+
+import (
+	"container/list"
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxPlans bounds the memos, least recently used plans are evicted, only shapes made of existing columns are memoized
+const maxPlans = 4096
+
+const (
+	columnPlanKind = "c\x00"
+	selectListKind = "s\x00"
+	skeletonKind   = "q\x00"
+)
+
+// planCache memoizes the schema dependent parts of statement building,
+// so repeated statement shapes only bind new args.
+// Plans are stored with generation they were built in, those built before reset are dropped.
+type planCache struct {
+	mu         sync.Mutex
+	generation uint64
+	recent     *list.List
+	items      map[string]*list.Element
+}
+
+type planEntry struct {
+	key   string
+	value interface{}
+}
+
+type columnPlan struct {
+	cols       []ColumnData
+	keys       []string
+	unresolved []string
+}
+
+// skeleton is query SQL around its where clause, the only part of query binding args besides virtual columns
+type skeleton struct {
+	head  string
+	tail  string
+	outer string
+}
+
+func newPlanCache() *planCache {
+	c := &planCache{}
+	c.reset()
+	return c
+}
+
+// get returns memoized plan, or generation to store plan built on miss with
+func (c *planCache) get(key string) (interface{}, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, c.generation, false
+	}
+	c.recent.MoveToFront(el)
+
+	return el.Value.(*planEntry).value, c.generation, true
+}
+
+func (c *planCache) store(key string, value interface{}, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+	if el, ok := c.items[key]; ok {
+		el.Value.(*planEntry).value = value
+		c.recent.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.recent.PushFront(&planEntry{key: key, value: value})
+	if c.recent.Len() > maxPlans {
+		oldest := c.recent.Back()
+		c.recent.Remove(oldest)
+		delete(c.items, oldest.Value.(*planEntry).key)
+	}
+}
+
+func (c *planCache) columnPlan(key string) (*columnPlan, uint64, bool) {
+	plan, generation, ok := c.get(columnPlanKind + key)
+	if !ok {
+		return nil, generation, false
+	}
+
+	return plan.(*columnPlan), generation, true
+}
+
+func (c *planCache) storeColumnPlan(key string, plan *columnPlan, generation uint64) {
+	c.store(columnPlanKind+key, plan, generation)
+}
+
+func (c *planCache) selectList(key string) (string, uint64, bool) {
+	list, generation, ok := c.get(selectListKind + key)
+	if !ok {
+		return "", generation, false
+	}
+
+	return list.(string), generation, true
+}
+
+func (c *planCache) storeSelectList(key string, list string, generation uint64) {
+	c.store(selectListKind+key, list, generation)
+}
+
+func (c *planCache) skeleton(key string) (*skeleton, uint64, bool) {
+	sk, generation, ok := c.get(skeletonKind + key)
+	if !ok {
+		return nil, generation, false
+	}
+
+	return sk.(*skeleton), generation, true
+}
+
+func (c *planCache) storeSkeleton(key string, sk *skeleton, generation uint64) {
+	c.store(skeletonKind+key, sk, generation)
+}
+
+func (c *planCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.recent = list.New()
+	c.items = make(map[string]*list.Element)
+}
+
+func columnPlanKey(relation string, values map[string]interface{}) string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return relation + "\x00" + strings.Join(keys, "\x00")
+}
+
+func selectPlanKey(relation string, columns []string, aliased bool) string {
+	if !aliased {
+		relation = "\x01" + relation
+	}
+
+	return relation + "\x00" + strings.Join(columns, "\x00")
+}
+
+// skeletonKey identifies query shape by relation, operation, selected columns and options,
+// role from context is part of it as readable columns depend on it
+func skeletonKey(ctx context.Context, s *QueryStatement, aggregate bool) string {
+	role, ok := RoleFromContext(ctx)
+	if !ok {
+		role = "\x01"
+	}
+	flags := []byte{'0', '0', '0', '0'}
+	for i, v := range []bool{s.nested, aggregate, s.distinct, s.withEtag} {
+		if v {
+			flags[i] = '1'
+		}
+	}
+
+	return strings.Join([]string{
+		strconv.Itoa(int(OpSelect)), s.target, s.from, string(flags), role,
+		strings.Join(s.selectCols, "\x01"), s.orderBy,
+		strconv.FormatUint(s.limit, 10), strconv.FormatUint(s.offset, 10),
+		s.lock, strings.Join(s.lockOf, "\x01"), s.lockWait,
+	}, "\x00")
+}
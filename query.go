@@ -2,7 +2,9 @@ package pgxjrep
 
 import (
 	"context"
+	"fmt"
 	"github.com/jackc/pgtype"
+	"github.com/tidwall/gjson"
 	"strconv"
 	"strings"
 )
@@ -11,13 +13,60 @@ type QueryStatement struct {
 	builder     *Builder
 	schema      *DbSchema
 	target      string
+	from        string
+	nested      bool
+	withClause  *withClause
 	selectCols  []string
 	distinct    bool
 	whereClause *whereClause
 	orderBy     string
 	limit       uint64
 	offset      uint64
+	lock        string
+	lockOf      []string
+	lockWait    string
+	withEtag    bool
+	rank        *rankColumn
+	similarity  *similarityColumn
 	params      *params
+	err         error
+}
+
+// With attaches statement as common table expression,
+// Insert, Update and Delete statements are attached as data-modifying CTEs
+func (s *QueryStatement) With(name string, statement Statement) *QueryStatement {
+	s.withClause.ctes = append(s.withClause.ctes, cte{name: name, statement: statement})
+	return s
+}
+
+// WithStatement attaches raw common table expression with question mark placeholders, as in WhereStatement
+func (s *QueryStatement) WithStatement(name string, statement string, args ...interface{}) *QueryStatement {
+	s.withClause.ctes = append(s.withClause.ctes, cte{name: name, rawStatement: statement, statementArgs: args})
+	return s
+}
+
+// Recursive emits WITH RECURSIVE, allowing attached raw CTEs to reference themselves
+func (s *QueryStatement) Recursive() *QueryStatement {
+	s.withClause.recursive = true
+	return s
+}
+
+// From selects from a CTE or other source that has the same columns as target
+func (s *QueryStatement) From(source string) *QueryStatement {
+	s.from = source
+	return s
+}
+
+// WithDeleted includes soft deleted rows
+func (s *QueryStatement) WithDeleted() *QueryStatement {
+	s.whereClause.scope = scopeWithDeleted
+	return s
+}
+
+// OnlyDeleted selects soft deleted rows only
+func (s *QueryStatement) OnlyDeleted() *QueryStatement {
+	s.whereClause.scope = scopeOnlyDeleted
+	return s
 }
 
 func (s *QueryStatement) Distinct() *QueryStatement {
@@ -46,6 +95,7 @@ func (s *QueryStatement) Filter(m map[string]interface{}) *QueryStatement {
 	return s
 }
 
+// OrderBy orders by comma separated columns with optional desc, each readable column of target or virtual rank and similarity column
 func (s *QueryStatement) OrderBy(clause string) *QueryStatement {
 	s.orderBy = clause
 	return s
@@ -61,50 +111,159 @@ func (s *QueryStatement) Offset(val uint64) *QueryStatement {
 	return s
 }
 
+func (s *QueryStatement) ForUpdate() *QueryStatement {
+	s.lock = "UPDATE"
+	return s
+}
+
+func (s *QueryStatement) ForNoKeyUpdate() *QueryStatement {
+	s.lock = "NO KEY UPDATE"
+	return s
+}
+
+func (s *QueryStatement) ForShare() *QueryStatement {
+	s.lock = "SHARE"
+	return s
+}
+
+func (s *QueryStatement) ForKeyShare() *QueryStatement {
+	s.lock = "KEY SHARE"
+	return s
+}
+
+func (s *QueryStatement) SkipLocked() *QueryStatement {
+	s.lockWait = "SKIP LOCKED"
+	return s
+}
+
+func (s *QueryStatement) NoWait() *QueryStatement {
+	s.lockWait = "NOWAIT"
+	return s
+}
+
+// Of restricts row locking to given relations, defaults to FOR UPDATE when no lock strength is set
+func (s *QueryStatement) Of(relations ...string) *QueryStatement {
+	if s.lock == "" {
+		s.lock = "UPDATE"
+	}
+	s.lockOf = relations
+	return s
+}
+
 func (s *QueryStatement) Build() (string, []interface{}) {
-	var q = "SELECT"
+	sql, args, err := s.BuildContext(context.Background())
+	if err != nil {
+		log.Panicln(err)
+	}
+
+	return sql, args
+}
+
+func (s *QueryStatement) BuildContext(ctx context.Context) (string, []interface{}, error) {
+	with, q, err := s.build(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return with + q, s.params.args, nil
+}
+
+// build returns WITH clause separately, wrapping statements keep it on top level as required for data-modifying CTEs
+func (s *QueryStatement) build(ctx context.Context) (with string, q string, err error) {
+	return s.buildQuery(ctx, false)
+}
+
+// buildQuery builds statement wrapped in aggregate by Count and Exists when aggregate is set,
+// virtual columns, ordering and row locks are left out as they are not used or not allowed with aggregate functions
+func (s *QueryStatement) buildQuery(ctx context.Context, aggregate bool) (with string, q string, err error) {
+	defer recoverBuildError(&err)
+
+	if s.err != nil {
+		return "", "", s.err
+	}
+	// nested statements bind to params of outer statement
+	if !s.nested {
+		s.params.reset()
+	}
+
+	with = s.withClause.build(ctx)
+
+	// virtual columns bind args in select list, other shapes bind args only in where clause and reuse their skeleton
+	var key string
+	var generation uint64
+	if s.rank == nil && s.similarity == nil {
+		key = skeletonKey(ctx, s, aggregate)
+		cached, g, ok := s.schema.plans.skeleton(key)
+		if ok {
+			return with, cached.build(s.whereClause.build(ctx)), nil
+		}
+		generation = g
+	}
+
+	q = "SELECT"
 
 	if s.distinct {
 		q += " DISTINCT"
 	}
 
-	if len(s.selectCols) > 0 {
-		var cols []string
-		for _, v := range s.schema.ResolveColumns(s.target, s.selectCols) {
-			if v.DbName == v.JsonName {
-				cols = append(cols, s.schema.Quote(v.DbName))
-			} else {
-				cols = append(cols, s.schema.Quote(v.DbName)+" AS "+s.schema.Quote(v.JsonName))
-			}
-		}
-		q += " " + strings.Join(cols, ", ")
+	cols := s.builder.perms.readColumns(ctx, s.target, s.selectCols)
+	if s.nested {
+		q += " " + s.schema.ColumnList(s.target, cols)
 	} else {
-		var cols []string
-		for _, v := range s.schema.ColSchema(s.target) {
-			json := s.schema.ToJsonCase(v.ColumnName)
-			if v.ColumnName == json {
-				cols = append(cols, s.schema.Quote(v.ColumnName))
-			} else {
-				cols = append(cols, s.schema.Quote(v.ColumnName)+" AS "+s.schema.Quote(json))
-			}
-		}
-		q += " " + strings.Join(cols, ", ")
+		q += " " + s.schema.SelectList(s.target, cols)
+	}
+
+	if s.rank != nil && !aggregate {
+		q += ", " + s.rankExpr(ctx)
+	}
+
+	if s.similarity != nil && !aggregate {
+		q += ", " + s.similarityExpr(ctx)
 	}
 
-	q += " FROM " + s.schema.QuoteRelation(s.target)
-	q += s.whereClause.build()
+	if s.withEtag {
+		q += ", " + s.schema.Quote(s.builder.versionColumn(s.target)) + "::text AS " + etagColumn
+	}
+
+	if s.from != "" {
+		q += " FROM " + s.schema.Quote(s.from)
+	} else {
+		q += " FROM " + s.schema.QuoteRelation(s.target)
+	}
+	head := q
+	where := s.whereClause.build(ctx)
+	q = ""
 
+	var expsNew []string
+	if s.similarity != nil {
+		expsNew = append(expsNew, s.schema.Quote(SimilarityColumn)+" DESC")
+	}
 	if s.orderBy != "" {
 		exps := strings.Split(s.orderBy, ",")
-		var expsNew []string
 		for _, v := range exps {
 			fls := strings.Fields(v)
+			if len(fls) == 0 {
+				continue
+			}
+			// only readable columns and virtual columns can be ordered by, expressions could disclose other columns
+			if _, ok := s.schema.ColMap(s.target)[fls[0]]; ok {
+				cols := s.schema.ResolveColumns(s.target, fls[:1])
+				s.builder.perms.checkRead(ctx, s.target, cols)
+				// json aliases are not available inside nested statements
+				if s.nested && len(cols) > 0 {
+					fls[0] = cols[0].DbName
+				}
+			} else if !(fls[0] == RankColumn && s.rank != nil) && !(fls[0] == SimilarityColumn && s.similarity != nil) {
+				throw(fmt.Errorf("order by column not found: %s", fls[0]))
+			}
 			if len(fls) > 1 && strings.ToUpper(fls[1]) == "DESC" {
 				expsNew = append(expsNew, s.schema.Quote(fls[0])+" DESC")
-			} else if len(fls) > 0 {
+			} else {
 				expsNew = append(expsNew, s.schema.Quote(fls[0]))
 			}
 		}
+	}
+	if len(expsNew) > 0 && !aggregate {
 		q += " ORDER BY " + strings.Join(expsNew, ", ")
 	}
 
@@ -116,16 +275,83 @@ func (s *QueryStatement) Build() (string, []interface{}) {
 		q += " OFFSET " + strconv.FormatUint(s.offset, 10)
 	}
 
-	return q, s.params.args
+	if s.lock != "" && !aggregate {
+		q += " FOR " + s.lock
+		if len(s.lockOf) > 0 {
+			var rels []string
+			for _, v := range s.lockOf {
+				_, rel := s.schema.resolveNames(v)
+				rels = append(rels, s.schema.Quote(rel))
+			}
+			q += " OF " + strings.Join(rels, ", ")
+		}
+		if s.lockWait != "" {
+			q += " " + s.lockWait
+		}
+	}
+
+	// range columns are converted to json by outer statement, so ORDER BY and DISTINCT compare ranges
+	sk := &skeleton{head: head, tail: q}
+	if !s.nested && !aggregate {
+		if list := s.schema.outputList(s.target, cols); list != "" {
+			if s.rank != nil {
+				list += ", " + s.schema.Quote(RankColumn)
+			}
+			if s.similarity != nil {
+				list += ", " + s.schema.Quote(SimilarityColumn)
+			}
+			if s.withEtag {
+				list += ", " + etagColumn
+			}
+			sk.outer = list
+		}
+	}
+	// select columns come from client input, unresolved ones are not memoized
+	if _, _, resolved := s.schema.selectedColumns(s.target, cols); key != "" && resolved {
+		s.schema.plans.storeSkeleton(key, sk, generation)
+	}
+
+	return with, sk.build(where), nil
+}
+
+func (sk *skeleton) build(where string) string {
+	q := sk.head + where + sk.tail
+	if sk.outer != "" {
+		q = "SELECT " + sk.outer + " FROM (" + q + ") t"
+	}
+
+	return q
+}
+
+// targetColumn resolves readable column of target for virtual column expressions
+func (s *QueryStatement) targetColumn(ctx context.Context, column string) ColumnData {
+	cols := s.schema.ResolveColumns(s.target, []string{column})
+	if len(cols) == 0 {
+		throw(fmt.Errorf("column not found: %s", column))
+	}
+	s.builder.perms.checkRead(ctx, s.target, cols)
+
+	return cols[0]
+}
+
+// source nests statement inside another, json aliases are applied by the outer statement only
+func (s *QueryStatement) source(p *params) {
+	s.nested = true
+	s.params = p
+	s.withClause.params = p
+	s.whereClause.params = p
 }
 
 func (s *QueryStatement) All(conn PgxConn, ctx context.Context) (string, error) {
-	sql, args := s.Build()
+	with, sql, err := s.build(ctx)
+	if err != nil {
+		return "", err
+	}
 
-	sql = "SELECT json_agg(t) as json FROM (" + sql + ") t;"
+	sql = with + "SELECT json_agg(t) as json FROM (" + sql + ") t;"
 
 	json := new(pgtype.Text)
-	err := conn.QueryRow(ctx, sql, args...).Scan(json)
+	err = conn.QueryRow(ctx, sql, s.params.args...).Scan(json)
 	if err != nil {
 		return "", err
 	}
@@ -137,12 +363,15 @@ func (s *QueryStatement) All(conn PgxConn, ctx context.Context) (string, error)
 }
 
 func (s *QueryStatement) One(conn PgxConn, ctx context.Context) (string, error) {
-	sql, args := s.Build()
+	with, sql, err := s.build(ctx)
+	if err != nil {
+		return "", err
+	}
 
-	sql = "SELECT row_to_json(t, false) as json FROM (" + sql + ") t;"
+	sql = with + "SELECT row_to_json(t, false) as json FROM (" + sql + ") t;"
 	jsn := new(string)
 
-	err := conn.QueryRow(ctx, sql, args...).Scan(jsn)
+	err = conn.QueryRow(ctx, sql, s.params.args...).Scan(jsn)
 	if err != nil {
 		return "", err
 	}
@@ -150,11 +379,31 @@ func (s *QueryStatement) One(conn PgxConn, ctx context.Context) (string, error)
 	return *jsn, nil
 }
 
+// OneWithETag returns single row and entity tag derived from its version column
+func (s *QueryStatement) OneWithETag(conn PgxConn, ctx context.Context) (json string, etag string, err error) {
+	defer recoverBuildError(&err)
+
+	if s.builder.versionColumn(s.target) == "" {
+		return "", "", ErrNoVersionColumn
+	}
+
+	s.withEtag = true
+	json, err = s.One(conn, ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	return cutEtag(json), ETag(gjson.Get(json, etagColumn).String()), nil
+}
+
 func (s *QueryStatement) Scalar(conn PgxConn, ctx context.Context) (interface{}, error) {
-	sql, args := s.Build()
+	sql, args, err := s.BuildContext(ctx)
+	if err != nil {
+		return "", err
+	}
 
 	scalar := new(interface{})
-	err := conn.QueryRow(ctx, sql, args...).Scan(scalar)
+	err = conn.QueryRow(ctx, sql, args...).Scan(scalar)
 	if err != nil {
 		return "", err
 	}
@@ -163,11 +412,14 @@ func (s *QueryStatement) Scalar(conn PgxConn, ctx context.Context) (interface{},
 }
 
 func (s *QueryStatement) Exists(conn PgxConn, ctx context.Context) (bool, error) {
-	sql, args := s.Build()
-	sql = "SELECT EXISTS(" + sql + ") as exists;"
+	with, sql, err := s.buildQuery(ctx, true)
+	if err != nil {
+		return false, err
+	}
+	sql = with + "SELECT EXISTS(" + sql + ") as exists;"
 
 	exists := new(bool)
-	err := conn.QueryRow(ctx, sql, args...).Scan(exists)
+	err = conn.QueryRow(ctx, sql, s.params.args...).Scan(exists)
 	if err != nil {
 		return false, err
 	}
@@ -176,12 +428,15 @@ func (s *QueryStatement) Exists(conn PgxConn, ctx context.Context) (bool, error)
 }
 
 func (s *QueryStatement) Count(conn PgxConn, ctx context.Context) (uint64, error) {
-	sql, args := s.Build()
-	fromInd := strings.Index(sql, "FROM")
-	sql = "SELECT COUNT(*) " + sql[fromInd:]
+	with, sql, err := s.buildQuery(ctx, true)
+	if err != nil {
+		return 0, err
+	}
+	fromInd := strings.Index(sql, " FROM ")
+	sql = with + "SELECT COUNT(*)" + sql[fromInd:]
 
 	count := new(uint64)
-	err := conn.QueryRow(ctx, sql, args...).Scan(count)
+	err = conn.QueryRow(ctx, sql, s.params.args...).Scan(count)
 	if err != nil {
 		return 0, err
 	}
@@ -54,10 +54,43 @@ func TestQueryBuild(t *testing.T) {
 		{str: builder.Query("test1").Filter(where2),
 			stm:  "SELECT id, a_a AS \"aA\", \"b_B\" AS \"bB\", cc_cc AS \"ccCc\" FROM test1 WHERE a_a ILIKE $1 AND \"b_B\" = $2",
 			args: append(args, "a%", 1)},
-		{str: builder.Query("test1").OrderBy("a_a, b_b desc"),
-			stm: "SELECT id, a_a AS \"aA\", \"b_B\" AS \"bB\", cc_cc AS \"ccCc\" FROM test1 ORDER BY a_a, b_b DESC"},
+		{str: builder.Query("test1").OrderBy("a_a, bB desc"),
+			stm: "SELECT id, a_a AS \"aA\", \"b_B\" AS \"bB\", cc_cc AS \"ccCc\" FROM test1 ORDER BY a_a, \"bB\" DESC"},
 		{str: builder.Query("test1").Limit(60).Offset(30),
 			stm: "SELECT id, a_a AS \"aA\", \"b_B\" AS \"bB\", cc_cc AS \"ccCc\" FROM test1 LIMIT 60 OFFSET 30"},
+		{str: builder.Query("test1").Limit(10).ForUpdate().SkipLocked(),
+			stm: "SELECT id, a_a AS \"aA\", \"b_B\" AS \"bB\", cc_cc AS \"ccCc\" FROM test1 LIMIT 10 FOR UPDATE SKIP LOCKED"},
+		{str: builder.Query("test1").ForNoKeyUpdate().NoWait(),
+			stm: "SELECT id, a_a AS \"aA\", \"b_B\" AS \"bB\", cc_cc AS \"ccCc\" FROM test1 FOR NO KEY UPDATE NOWAIT"},
+		{str: builder.Query("test1").ForShare(),
+			stm: "SELECT id, a_a AS \"aA\", \"b_B\" AS \"bB\", cc_cc AS \"ccCc\" FROM test1 FOR SHARE"},
+		{str: builder.Query("test.Test2").ForKeyShare().Of("test.Test2"),
+			stm: "SELECT \"Id\" AS id, \"X\" AS x, \"Y\" AS y, \"Z\" AS z FROM test.\"Test2\" FOR KEY SHARE OF \"Test2\""},
+		{str: builder.Query("test1").Where(map[string]interface{}{
+			"id": builder.Query("test.Test2").Select("id").Where(map[string]interface{}{"y": 5}),
+			"aA": "a",
+		}),
+			stm:  "SELECT id, a_a AS \"aA\", \"b_B\" AS \"bB\", cc_cc AS \"ccCc\" FROM test1 WHERE id IN (SELECT \"Id\" FROM test.\"Test2\" WHERE \"Y\" = $1) AND a_a LIKE $2",
+			args: append(args, 5, "a")},
+		{str: builder.Query("test1").Where(map[string]interface{}{
+			"bB":              1,
+			pgxjrep.ExistsKey: builder.Query("test.Test2").WhereStatement("\"X\" = test1.a_a AND \"Y\" > ?", 3),
+		}),
+			stm:  "SELECT id, a_a AS \"aA\", \"b_B\" AS \"bB\", cc_cc AS \"ccCc\" FROM test1 WHERE EXISTS (SELECT \"Id\", \"X\", \"Y\", \"Z\" FROM test.\"Test2\" WHERE \"X\" = test1.a_a AND \"Y\" > $1) AND \"b_B\" = $2",
+			args: append(args, 3, 1)},
+		{str: builder.Query("test.Test2").
+			With("moved", builder.Delete("test.Test2").Where(map[string]interface{}{"y": 1}).Returning("id", "x")).
+			From("moved").
+			Select("id", "x"),
+			stm:  "WITH moved AS (DELETE FROM test.\"Test2\" WHERE \"Y\" = $1 RETURNING \"Id\", \"X\") SELECT \"Id\" AS id, \"X\" AS x FROM moved",
+			args: append(args, 1)},
+		{str: builder.Query("test1").
+			WithStatement("tree", "SELECT * FROM test1 WHERE id = ? UNION ALL SELECT c.* FROM test1 c JOIN tree t ON c.\"b_B\" = t.id", 1).
+			Recursive().
+			From("tree").
+			Where(map[string]interface{}{"aA": "a"}),
+			stm:  "WITH RECURSIVE tree AS (SELECT * FROM test1 WHERE id = $1 UNION ALL SELECT c.* FROM test1 c JOIN tree t ON c.\"b_B\" = t.id) SELECT id, a_a AS \"aA\", \"b_B\" AS \"bB\", cc_cc AS \"ccCc\" FROM tree WHERE a_a LIKE $2",
+			args: append(args, 1, "a")},
 	}
 
 	for _, v := range buildResults {
@@ -65,6 +98,13 @@ func TestQueryBuild(t *testing.T) {
 		assert.Equal(t, v.stm, stm)
 		assert.Equal(t, v.args, argsOut)
 	}
+
+	// repeated shapes reuse memoized skeleton and only bind new args
+	for _, v := range []int{1, 2} {
+		stm, argsOut := builder.Query("test1").Select("aA").Where(map[string]interface{}{"id": v}).OrderBy("aA").Limit(5).Build()
+		assert.Equal(t, "SELECT a_a AS \"aA\" FROM test1 WHERE id = $1 ORDER BY \"aA\" LIMIT 5", stm)
+		assert.Equal(t, append(args, v), argsOut)
+	}
 }
 
 func TestQueryExec(t *testing.T) {
@@ -88,3 +128,285 @@ func TestQueryExec(t *testing.T) {
 	assert.Equal(t, "[{\"id\":1,\"x\":\"a\",\"y\":1,\"z\":true}, \n {\"id\":2,\"x\":\"c\",\"y\":3,\"z\":true}, \n {\"id\":3,\"x\":\"a\",\"y\":1,\"z\":true}]", json)
 	assert.Equal(t, nil, err)
 }
+
+func TestQueryTenant(t *testing.T) {
+	Init(t)
+	builder.SetTenantPolicy(&pgxjrep.TenantPolicy{Column: "tenant_id"})
+	defer builder.SetTenantPolicy(nil)
+
+	tenantCtx := pgxjrep.ContextWithTenant(ctx, 7)
+
+	stm, argsOut, err := builder.Query("test_tenant").Where(map[string]interface{}{"name": "a"}).BuildContext(tenantCtx)
+	assert.Equal(t, "SELECT id, tenant_id AS \"tenantId\", name FROM test_tenant WHERE name LIKE $1 AND tenant_id = $2", stm)
+	assert.Equal(t, append(args, "a", 7), argsOut)
+	assert.Equal(t, nil, err)
+
+	stm, argsOut, err = builder.Insert("test_tenant").Values(map[string]interface{}{"name": "a", "tenantId": 9}).BuildContext(tenantCtx)
+	assert.Equal(t, "INSERT INTO test_tenant (name, tenant_id) VALUES ($1, $2)", stm)
+	assert.Equal(t, append(args, "a", 7), argsOut)
+	assert.Equal(t, nil, err)
+
+	stm, argsOut, err = builder.Update("test_tenant").SetWherePk(map[string]interface{}{"id": 1, "name": "a"}).BuildContext(tenantCtx)
+	assert.Equal(t, "UPDATE test_tenant SET name = $1 WHERE id = $2 AND tenant_id = $3", stm)
+	assert.Equal(t, append(args, "a", 1, 7), argsOut)
+	assert.Equal(t, nil, err)
+
+	stm, argsOut, err = builder.Delete("test_tenant").Where(pk1).BuildContext(tenantCtx)
+	assert.Equal(t, "DELETE FROM test_tenant WHERE id = $1 AND tenant_id = $2", stm)
+	assert.Equal(t, append(args, 11, 7), argsOut)
+	assert.Equal(t, nil, err)
+
+	_, _, err = builder.Query("test_tenant").BuildContext(ctx)
+	assert.Equal(t, pgxjrep.ErrMissingTenant, err)
+
+	_, err = builder.Query("test_tenant").All(conn, ctx)
+	assert.Equal(t, pgxjrep.ErrMissingTenant, err)
+
+	stm, _, err = builder.Query("test1").BuildContext(ctx)
+	assert.Equal(t, "SELECT id, a_a AS \"aA\", \"b_B\" AS \"bB\", cc_cc AS \"ccCc\" FROM test1", stm)
+	assert.Equal(t, nil, err)
+}
+
+func TestQueryPermissions(t *testing.T) {
+	Init(t)
+	builder.SetPermissionPolicy(pgxjrep.PermissionPolicy{
+		"reader": {"test1": {Read: []string{"id", "aA"}, Write: []string{}}, "test.Test2": {Read: []string{"x"}}},
+		"writer": {"test1": {Write: []string{"aA"}}},
+	})
+	defer builder.SetPermissionPolicy(nil)
+
+	readerCtx := pgxjrep.ContextWithRole(ctx, "reader")
+	writerCtx := pgxjrep.ContextWithRole(ctx, "writer")
+
+	stm, _, err := builder.Query("test1").BuildContext(readerCtx)
+	assert.Equal(t, "SELECT id, a_a AS \"aA\" FROM test1", stm)
+	assert.Equal(t, nil, err)
+
+	_, _, err = builder.Query("test1").Select("bB").BuildContext(readerCtx)
+	assert.Equal(t, &pgxjrep.PermissionError{Relation: "test1", Column: "bB"}, err)
+
+	_, _, err = builder.Query("test1").Where(map[string]interface{}{"ccCc": true}).BuildContext(readerCtx)
+	assert.Equal(t, &pgxjrep.PermissionError{Relation: "test1", Column: "ccCc"}, err)
+
+	_, _, err = builder.Query("test1").OrderBy("bB desc").BuildContext(readerCtx)
+	assert.Equal(t, &pgxjrep.PermissionError{Relation: "test1", Column: "bB"}, err)
+
+	_, _, err = builder.Query("test1").OrderBy("lower(bB)").BuildContext(readerCtx)
+	assert.EqualError(t, err, "order by column not found: lower(bB)")
+
+	_, _, err = builder.Query("test1").OrderBy("\"b_B\"").BuildContext(readerCtx)
+	assert.EqualError(t, err, "order by column not found: \"b_B\"")
+
+	_, _, err = builder.Delete("test1").Using("test.Test2").Where(map[string]interface{}{
+		"id": pgxjrep.Column("test.Test2.Y"),
+	}).BuildContext(readerCtx)
+	assert.Equal(t, &pgxjrep.PermissionError{Relation: "test.Test2", Column: "y"}, err)
+
+	_, _, err = builder.Insert("test1").Values(map[string]interface{}{"aA": "a"}).BuildContext(readerCtx)
+	assert.Equal(t, &pgxjrep.PermissionError{Relation: "test1", Column: "aA", Write: true}, err)
+
+	stm, _, err = builder.Update("test1").SetWherePk(map[string]interface{}{"id": 1, "aA": "a"}).BuildContext(writerCtx)
+	assert.Equal(t, "UPDATE test1 SET a_a = $1 WHERE id = $2", stm)
+	assert.Equal(t, nil, err)
+
+	_, _, err = builder.Update("test1").Set(map[string]interface{}{"bB": 1}).BuildContext(writerCtx)
+	assert.Equal(t, &pgxjrep.PermissionError{Relation: "test1", Column: "bB", Write: true}, err)
+
+	stm, _, err = builder.Query("test1").BuildContext(writerCtx)
+	assert.Equal(t, "SELECT id, a_a AS \"aA\", \"b_B\" AS \"bB\", cc_cc AS \"ccCc\" FROM test1", stm)
+	assert.Equal(t, nil, err)
+
+	_, _, err = builder.Query("test1").BuildContext(ctx)
+	assert.Equal(t, pgxjrep.ErrMissingRole, err)
+
+	stm, _, err = builder.Query("test.Test2").Select("x").BuildContext(readerCtx)
+	assert.Equal(t, "SELECT \"X\" AS x FROM test.\"Test2\"", stm)
+	assert.Equal(t, nil, err)
+}
+
+func TestQueryJson(t *testing.T) {
+	Init(t)
+
+	stm, argsOut := builder.Query("test_jsonb").Where(map[string]interface{}{
+		"meta": map[string]interface{}{"tags": []string{"a"}},
+	}).Build()
+	assert.Equal(t, "SELECT id, meta, doc FROM test_jsonb WHERE meta @> $1::jsonb", stm)
+	assert.Equal(t, append(args, "{\"tags\":[\"a\"]}"), argsOut)
+
+	stm, argsOut = builder.Query("test_jsonb").Where(map[string]interface{}{
+		"meta.address.city": "Oslo",
+		"meta.floor":        2,
+	}).Build()
+	assert.Equal(t, "SELECT id, meta, doc FROM test_jsonb WHERE meta #>> $1::text[] = $2 AND (meta #> $3::text[]) = $4::jsonb", stm)
+	assert.Equal(t, append(args, []string{"address", "city"}, "Oslo", []string{"floor"}, "2"), argsOut)
+
+	stm, argsOut = builder.Query("test_jsonb").Filter(map[string]interface{}{
+		"meta": map[string]interface{}{"$hasKey": "address", "$hasAnyKeys": []string{"a", "b"}},
+		"doc":  map[string]interface{}{"$jsonPath": "$.tags[*] ? (@ == \"a\")"},
+	}).Build()
+	assert.Equal(t, "SELECT id, meta, doc FROM test_jsonb WHERE meta ?| $1::text[] AND meta ? $2 AND jsonb_path_exists(doc::jsonb, $3::jsonpath)", stm)
+	assert.Equal(t, append(args, []string{"a", "b"}, "address", "$.tags[*] ? (@ == \"a\")"), argsOut)
+
+	stm, argsOut = builder.Query("test_jsonb").Where(map[string]interface{}{
+		"meta.address": map[string]interface{}{"$contains": map[string]interface{}{"city": "Oslo"}},
+	}).Build()
+	assert.Equal(t, "SELECT id, meta, doc FROM test_jsonb WHERE (meta #> $1::text[]) @> $2::jsonb", stm)
+	assert.Equal(t, append(args, []string{"address"}, "{\"city\":\"Oslo\"}"), argsOut)
+
+	stm, argsOut = builder.Query("test_jsonb").Where(map[string]interface{}{"meta.address.city": nil}).Build()
+	assert.Equal(t, "SELECT id, meta, doc FROM test_jsonb WHERE (meta #> $1::text[]) IS NULL", stm)
+	assert.Equal(t, append(args, []string{"address", "city"}), argsOut)
+
+	stm, _ = builder.Query("test_jsonb").Select("id", "meta.address").Build()
+	assert.Equal(t, "SELECT id, meta #> '{\"address\"}' AS \"address\" FROM test_jsonb", stm)
+
+	_, _, err := builder.Query("test1").Where(map[string]interface{}{"aA": map[string]interface{}{"$hasKey": "a"}}).BuildContext(ctx)
+	assert.EqualError(t, err, "operator $hasKey is not supported on column aA of type text")
+}
+
+func TestQueryArray(t *testing.T) {
+	Init(t)
+
+	stm, argsOut := builder.Query("test_array").Where(map[string]interface{}{
+		"tags":   map[string]interface{}{"$contains": []string{"a"}, "$overlaps": []string{"b", "c"}},
+		"scores": map[string]interface{}{"$any": 10},
+	}).Build()
+	assert.Equal(t, "SELECT id, tags, scores FROM test_array WHERE tags @> $1::text[] AND tags && $2::text[] AND $3::integer = ANY(scores)", stm)
+	assert.Equal(t, append(args, []string{"a"}, []string{"b", "c"}, 10), argsOut)
+
+	stm, argsOut = builder.Query("test_array").Filter(map[string]interface{}{
+		"id":   map[string]interface{}{"$any": []int{1, 2}},
+		"tags": map[string]interface{}{"$containedBy": []string{"a", "b"}},
+	}).Build()
+	assert.Equal(t, "SELECT id, tags, scores FROM test_array WHERE id = ANY($1::integer[]) AND tags <@ $2::text[]", stm)
+	assert.Equal(t, append(args, []int{1, 2}, []string{"a", "b"}), argsOut)
+
+	_, _, err := builder.Query("test_jsonb").Where(map[string]interface{}{"meta": map[string]interface{}{"$any": []string{"a"}}}).BuildContext(ctx)
+	assert.EqualError(t, err, "operator $any is not supported on column meta of type jsonb")
+
+	_, _, err = builder.Query("test_range").Where(map[string]interface{}{"period": map[string]interface{}{"$any": []string{"[1,2)"}}}).BuildContext(ctx)
+	assert.EqualError(t, err, "operator $any is not supported on column period of type tstzrange")
+}
+
+func TestQueryRange(t *testing.T) {
+	Init(t)
+
+	period := "CASE WHEN period IS NULL THEN NULL WHEN isempty(period) THEN json_build_object('empty', true) " +
+		"ELSE json_build_object('lower', lower(period), 'upper', upper(period), 'bounds', " +
+		"CASE WHEN lower_inc(period) THEN '[' ELSE '(' END || CASE WHEN upper_inc(period) THEN ']' ELSE ')' END) END AS period"
+
+	stm, argsOut := builder.Query("test_range").Select("id", "period").Where(map[string]interface{}{
+		"period": map[string]interface{}{
+			"$overlaps":      map[string]interface{}{"lower": "2021-01-01T00:00:00Z", "upper": "2021-01-02T00:00:00Z"},
+			"$containsPoint": "2021-01-01T12:00:00Z",
+		},
+		"seats": map[string]interface{}{"$adjacent": "[1,5)", "$strictlyLeft": map[string]interface{}{"lower": 10, "bounds": "(]"}},
+	}).Build()
+	assert.Equal(t, "SELECT id, "+period+" FROM (SELECT id, period FROM test_range WHERE period @> $1::timestamp with time zone "+
+		"AND period && tstzrange($2::timestamp with time zone, $3::timestamp with time zone, $4) "+
+		"AND seats -|- $5::int4range AND seats << int4range($6::integer, $7::integer, $8)) t", stm)
+	assert.Equal(t, append(args, "2021-01-01T12:00:00Z", "2021-01-01T00:00:00Z", "2021-01-02T00:00:00Z", "[)", "[1,5)", 10, nil, "(]"), argsOut)
+
+	stm, _ = builder.Query("test_range").Select("id", "period").Distinct().OrderBy("period desc").Build()
+	assert.Equal(t, "SELECT id, "+period+" FROM (SELECT DISTINCT id, period FROM test_range ORDER BY period DESC) t", stm)
+
+	_, err := builder.Query("test_range").Distinct().OrderBy("period desc").All(conn, ctx)
+	assert.Equal(t, nil, err)
+
+	_, _, err = builder.Query("test_range").Where(map[string]interface{}{
+		"seats": map[string]interface{}{"$overlaps": map[string]interface{}{"lower": 1, "bounds": "[["}},
+	}).BuildContext(ctx)
+	assert.EqualError(t, err, "bounds of range column seats must be one of \"[)\", \"[]\", \"(]\", \"()\"")
+}
+
+func TestQuerySearch(t *testing.T) {
+	Init(t)
+
+	stm, argsOut := builder.Query("test_search").Select("id").Where(map[string]interface{}{
+		"body":     map[string]interface{}{"$search": "fat cat"},
+		"document": map[string]interface{}{"$search": "rat"},
+	}).Build()
+	assert.Equal(t, "SELECT id FROM test_search WHERE to_tsvector(body) @@ websearch_to_tsquery($1) "+
+		"AND document @@ websearch_to_tsquery($2)", stm)
+	assert.Equal(t, append(args, "fat cat", "rat"), argsOut)
+
+	builder.SearchConfig("test_search", "english")
+	defer builder.SearchConfig("test_search", "")
+	stm, argsOut = builder.Query("test_search").Select("id").Rank("body", "fat cat").Where(map[string]interface{}{
+		"body": map[string]interface{}{"$search": "fat cat"},
+	}).OrderBy("rank desc").Build()
+	assert.Equal(t, "SELECT id, ts_rank(to_tsvector('english'::regconfig, body), websearch_to_tsquery('english'::regconfig, $1)) AS rank "+
+		"FROM test_search WHERE to_tsvector('english'::regconfig, body) @@ websearch_to_tsquery('english'::regconfig, $2) ORDER BY rank DESC", stm)
+	assert.Equal(t, append(args, "fat cat", "fat cat"), argsOut)
+
+	// count leaves rank out of its own statement only
+	q := builder.Query("test_search").Select("id").Rank("body", "fat cat").OrderBy("rank desc")
+	_, err := q.Count(conn, ctx)
+	assert.Equal(t, nil, err)
+	stm, argsOut = q.Build()
+	assert.Equal(t, "SELECT id, ts_rank(to_tsvector('english'::regconfig, body), websearch_to_tsquery('english'::regconfig, $1)) AS rank "+
+		"FROM test_search ORDER BY rank DESC", stm)
+	assert.Equal(t, append(args, "fat cat"), argsOut)
+
+	_, _, err = builder.Query("test_search").Where(map[string]interface{}{
+		"id": map[string]interface{}{"$search": "cat"},
+	}).BuildContext(ctx)
+	assert.EqualError(t, err, "operator $search is not supported on column id of type integer")
+}
+
+func TestQuerySimilarity(t *testing.T) {
+	Init(t)
+
+	stm, argsOut := builder.Query("test_search").Select("id", "body").Where(map[string]interface{}{
+		"body": map[string]interface{}{"$similar": "jon", "$wordSimilar": "smith"},
+	}).OrderBySimilarity("body", "jon smith").OrderBy("id").Build()
+	assert.Equal(t, "SELECT id, body, similarity(body, $1) AS similarity FROM test_search "+
+		"WHERE body % $2 AND $3 <% body ORDER BY similarity DESC, id", stm)
+	assert.Equal(t, append(args, "jon smith", "jon", "smith"), argsOut)
+
+	q := builder.Query("test_search").Select("id").OrderBySimilarity("body", "jon")
+	_, err := q.Count(conn, ctx)
+	assert.Equal(t, nil, err)
+	stm, argsOut = q.Build()
+	assert.Equal(t, "SELECT id, similarity(body, $1) AS similarity FROM test_search ORDER BY similarity DESC", stm)
+	assert.Equal(t, append(args, "jon"), argsOut)
+
+	_, _, err = builder.Query("test_search").Where(map[string]interface{}{
+		"id": map[string]interface{}{"$similar": "1"},
+	}).BuildContext(ctx)
+	assert.EqualError(t, err, "operator $similar is not supported on column id of type integer")
+}
+
+func TestQueryMatch(t *testing.T) {
+	Init(t)
+
+	stm, argsOut := builder.Query("test1").Select("id").Where(map[string]interface{}{"aA": `50%_off\`}).Build()
+	assert.Equal(t, "SELECT id FROM test1 WHERE a_a LIKE $1", stm)
+	assert.Equal(t, append(args, `50\%\_off\\`), argsOut)
+
+	stm, argsOut = builder.Query("test1").Select("id").Filter(map[string]interface{}{"aA": "a_"}).Build()
+	assert.Equal(t, "SELECT id FROM test1 WHERE a_a ILIKE $1", stm)
+	assert.Equal(t, append(args, `a\_%`), argsOut)
+
+	stm, argsOut = builder.Query("test1").Select("id").Match(pgxjrep.MatchExact).Where(map[string]interface{}{"aA": "a%"}).Build()
+	assert.Equal(t, "SELECT id FROM test1 WHERE a_a = $1", stm)
+	assert.Equal(t, append(args, "a%"), argsOut)
+
+	stm, argsOut = builder.Query("test1").Select("id").Match(pgxjrep.MatchILike).Collate("und-x-icu").Where(map[string]interface{}{"aA": "Ä"}).Build()
+	assert.Equal(t, "SELECT id FROM test1 WHERE a_a COLLATE \"und-x-icu\" ILIKE $1", stm)
+	assert.Equal(t, append(args, "Ä"), argsOut)
+
+	stm, argsOut = builder.Query("test1").Select("id").Match(pgxjrep.MatchLike).Filter(map[string]interface{}{"aA": "A"}).Build()
+	assert.Equal(t, "SELECT id FROM test1 WHERE a_a LIKE $1", stm)
+	assert.Equal(t, append(args, "A%"), argsOut)
+}
+
+func TestQueryAggregateLock(t *testing.T) {
+	Init(t)
+
+	_, err := builder.Query("test1").ForUpdate().SkipLocked().Count(conn, ctx)
+	assert.Equal(t, nil, err)
+
+	_, err = builder.Query("test1").ForShare().NoWait().Exists(conn, ctx)
+	assert.Equal(t, nil, err)
+}
@@ -0,0 +1,76 @@
+package pgxjrep
+
+import (
+	"fmt"
+	"strings"
+)
+
+// range operator keys, $contains and $overlaps are shared with arrays
+const (
+	ContainsPointKey = "$containsPoint"
+	AdjacentKey      = "$adjacent"
+	StrictlyLeftKey  = "$strictlyLeft"
+)
+
+// DefaultRangeBounds are bounds of range value objects without "bounds" key
+const DefaultRangeBounds = "[)"
+
+func (v ColumnData) isRange() bool {
+	return v.class == ClassRange && v.dimension == 0
+}
+
+// rangeOperator returns condition of range operator, ranges are given as {"lower": ..., "upper": ..., "bounds": "[)"}
+// objects or range literals
+func (c *whereClause) rangeOperator(v ColumnData, op string, value interface{}) (string, bool) {
+	if !v.isRange() {
+		return "", false
+	}
+
+	col := c.column(v)
+	switch op {
+	case OverlapsKey:
+		return col + " && " + c.schema.rangeValue(v, value, c.params), true
+	case ContainsKey:
+		return col + " @> " + c.schema.rangeValue(v, value, c.params), true
+	case ContainsPointKey:
+		return col + " @> " + c.params.get(value) + "::" + v.subtype, true
+	case AdjacentKey:
+		return col + " -|- " + c.schema.rangeValue(v, value, c.params), true
+	case StrictlyLeftKey:
+		return col + " << " + c.schema.rangeValue(v, value, c.params), true
+	}
+
+	return "", false
+}
+
+// rangeValue binds range object with range constructor, other values are cast to range type,
+// missing or null lower and upper are unbounded
+func (s *DbSchema) rangeValue(v ColumnData, value interface{}, prm *params) string {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return prm.get(value) + "::" + v.dataType
+	}
+
+	bounds := DefaultRangeBounds
+	if b, ok := m["bounds"]; ok && b != nil {
+		bounds, ok = b.(string)
+		if !ok || len(bounds) != 2 || !strings.ContainsAny(bounds[:1], "[(") || !strings.ContainsAny(bounds[1:], "])") {
+			throw(fmt.Errorf("bounds of range column %s must be one of \"[)\", \"[]\", \"(]\", \"()\"", v.JsonName))
+		}
+	}
+	for k := range m {
+		if k != "lower" && k != "upper" && k != "bounds" {
+			throw(fmt.Errorf("range value of column %s has unknown key %s", v.JsonName, k))
+		}
+	}
+
+	return v.dataType + "(" + prm.get(m["lower"]) + "::" + v.subtype + ", " + prm.get(m["upper"]) + "::" + v.subtype + ", " + prm.get(bounds) + ")"
+}
+
+// rangeJson returns range column expression as json object {"lower": ..., "upper": ..., "bounds": "[)"},
+// empty ranges as {"empty": true}
+func (s *DbSchema) rangeJson(col string) string {
+	return "CASE WHEN " + col + " IS NULL THEN NULL WHEN isempty(" + col + ") THEN json_build_object('empty', true) " +
+		"ELSE json_build_object('lower', lower(" + col + "), 'upper', upper(" + col + "), 'bounds', " +
+		"CASE WHEN lower_inc(" + col + ") THEN '[' ELSE '(' END || CASE WHEN upper_inc(" + col + ") THEN ']' ELSE ')' END) END"
+}
@@ -0,0 +1,90 @@
+package pgxjrep
+
+import (
+	"context"
+	"fmt"
+)
+
+// relation kinds as in pg_class.relkind
+const (
+	RelationTable            = "r"
+	RelationPartitioned      = "p"
+	RelationView             = "v"
+	RelationMaterializedView = "m"
+	RelationForeign          = "f"
+)
+
+// bits of pg_relation_is_updatable
+const (
+	updatableUpdate = 1 << 2
+	updatableInsert = 1 << 3
+	updatableDelete = 1 << 4
+)
+
+type RelationSchema struct {
+	SchemaName   string
+	RelationName string
+	Kind         string
+	IsInsertable bool
+	IsUpdatable  bool
+	IsDeletable  bool
+}
+
+// NotUpdatableError is returned when statement writes to relation that does not allow it, e.g. materialized view
+type NotUpdatableError struct {
+	Relation  string
+	Kind      string
+	Operation Operation
+}
+
+func (e *NotUpdatableError) Error() string {
+	return fmt.Sprintf("relation %s of kind %s does not allow %s", e.Relation, e.Kind, e.Operation)
+}
+
+// RelationSchema returns kind and updatability of relation, views are updatable when they are automatically
+// updatable or have INSTEAD OF triggers
+func (s *DbSchema) RelationSchema(relation string) RelationSchema {
+	sch, rel := s.resolveNames(relation)
+	col := s.colSchema[sch][rel][0]
+
+	return RelationSchema{
+		SchemaName:   sch,
+		RelationName: rel,
+		Kind:         col.RelationKind,
+		IsInsertable: col.RelationUpdatable&updatableInsert != 0,
+		IsUpdatable:  col.RelationUpdatable&updatableUpdate != 0,
+		IsDeletable:  col.RelationUpdatable&updatableDelete != 0,
+	}
+}
+
+// checkWritable fails the build when relation does not allow operation
+func (s *DbSchema) checkWritable(relation string, op Operation) {
+	r := s.RelationSchema(relation)
+	allowed := op == OpInsert && r.IsInsertable || op == OpUpdate && r.IsUpdatable || op == OpDelete && r.IsDeletable
+	if !allowed {
+		throw(&NotUpdatableError{Relation: relation, Kind: r.Kind, Operation: op})
+	}
+}
+
+// RefreshMaterializedView replaces contents of materialized view, concurrently refreshes it without locking out
+// concurrent selects and requires unique index on the view
+func (b *Builder) RefreshMaterializedView(conn PgxConn, ctx context.Context, relation string, concurrently bool) (json string, err error) {
+	defer recoverBuildError(&err)
+
+	if kind := b.RelationSchema(relation).Kind; kind != RelationMaterializedView {
+		return "", fmt.Errorf("relation %s of kind %s is not a materialized view", relation, kind)
+	}
+
+	sql := "REFRESH MATERIALIZED VIEW "
+	if concurrently {
+		sql += "CONCURRENTLY "
+	}
+	sql += b.QuoteRelation(relation)
+
+	_, err = conn.Exec(ctx, sql)
+	if err != nil {
+		return "", err
+	}
+
+	return "{\"refreshed\": true}", nil
+}
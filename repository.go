@@ -3,6 +3,7 @@ package pgxjrep
 import (
 	"context"
 	"fmt"
+	"github.com/jackc/pgx/v4"
 	"math"
 )
 
@@ -20,8 +21,71 @@ func New(builder *Builder, conn PgxConn, ctx context.Context) *Repository {
 	}
 }
 
+// run resolves target resource allowing operation op and executes f on repository connection,
+// inside transaction applying session settings when context carries them
+func (r *Repository) run(target string, op Operation, f func(conn PgxConn, relation string) (string, error)) (string, error) {
+	relation, err := r.builder.Resource(target, op)
+	if err != nil {
+		return "", err
+	}
+	// ambiguous names depend on search path of database, they are reported before anything is built
+	if op != OpCall {
+		if _, _, err := r.builder.lookup(relation); err != nil {
+			if _, ok := err.(*AmbiguousRelationError); ok {
+				return "", err
+			}
+		}
+	}
+
+	settings := SettingsFromContext(r.ctx)
+	if len(settings) == 0 {
+		return f(r.conn, relation)
+	}
+
+	beginner, ok := r.conn.(PgxBeginner)
+	if !ok {
+		return "", ErrNoTransactions
+	}
+
+	tx, err := beginner.Begin(r.ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(r.ctx)
+
+	// on caller transaction Begin creates savepoint, settings outlive its release unless previous values are restored
+	var previous map[string]string
+	if _, ok := r.conn.(pgx.Tx); ok {
+		previous, err = r.builder.currentSettings(tx, r.ctx, settings)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	err = r.builder.applySettings(tx, r.ctx, settings)
+	if err != nil {
+		return "", err
+	}
+
+	res, err := f(tx, relation)
+	if err != nil {
+		return "", err
+	}
+
+	if previous != nil {
+		err = r.builder.applySettings(tx, r.ctx, previous)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return res, tx.Commit(r.ctx)
+}
+
 func (r *Repository) All(target string) (string, error) {
-	return r.builder.Query(target).All(r.conn, r.ctx)
+	return r.run(target, OpSelect, func(conn PgxConn, relation string) (string, error) {
+		return r.builder.query(relation).All(conn, r.ctx)
+	})
 }
 
 func (r *Repository) Filter(target string, values map[string]interface{}, orderBy string, page uint64, pageSize uint64) (string, error) {
@@ -29,49 +93,142 @@ func (r *Repository) Filter(target string, values map[string]interface{}, orderB
 		page = 1
 	}
 	offset := (page - 1) * pageSize
-	return r.builder.Query(target).
-		Filter(values).
-		OrderBy(orderBy).
-		Offset(offset).
-		Limit(pageSize).
-		All(r.conn, r.ctx)
+	return r.run(target, OpSelect, func(conn PgxConn, relation string) (string, error) {
+		return r.builder.query(relation).
+			Filter(values).
+			OrderBy(orderBy).
+			Offset(offset).
+			Limit(pageSize).
+			All(conn, r.ctx)
+	})
 }
 
 func (r *Repository) Pages(target string, values map[string]interface{}, pageSize uint64) (string, error) {
-	cnt, err := r.builder.Query(target).Filter(values).Count(r.conn, r.ctx)
-	if err != nil {
-		return "", err
-	}
+	return r.run(target, OpSelect, func(conn PgxConn, relation string) (string, error) {
+		cnt, err := r.builder.query(relation).Filter(values).Count(conn, r.ctx)
+		if err != nil {
+			return "", err
+		}
 
-	var pages float64
-	if cnt != 0 {
-		pages = math.Ceil(float64(cnt / pageSize))
-	}
+		var pages float64
+		if cnt != 0 {
+			pages = math.Ceil(float64(cnt / pageSize))
+		}
 
-	return fmt.Sprintf("{\"pages\": %v}", pages), nil
+		return fmt.Sprintf("{\"pages\": %v}", pages), nil
+	})
 }
 
 func (r *Repository) OneByPk(target string, pk map[string]interface{}) (string, error) {
-	return r.builder.Query(target).Where(pk).One(r.conn, r.ctx)
+	return r.run(target, OpSelect, func(conn PgxConn, relation string) (string, error) {
+		return r.builder.query(relation).Where(pk).One(conn, r.ctx)
+	})
 }
 
-func (r *Repository) Insert(target string, values map[string]interface{}, returning ...string) (string, error) {
-	if len(returning) > 0 {
-		return r.builder.Insert(target).Values(values).Returning(returning...).One(r.conn, r.ctx)
+// OneByPkWithETag returns row and entity tag of its version, to be sent back as If-Match on Update
+func (r *Repository) OneByPkWithETag(target string, pk map[string]interface{}) (string, string, error) {
+	var etag string
+	json, err := r.run(target, OpSelect, func(conn PgxConn, relation string) (string, error) {
+		var json string
+		var err error
+		json, etag, err = r.builder.query(relation).Where(pk).OneWithETag(conn, r.ctx)
+		return json, err
+	})
+	if err != nil {
+		return "", "", err
 	}
-	return r.builder.Insert(target).Values(values).Exec(r.conn, r.ctx)
+
+	return json, etag, nil
+}
+
+func (r *Repository) Insert(target string, values map[string]interface{}, returning ...string) (string, error) {
+	return r.run(target, OpInsert, func(conn PgxConn, relation string) (string, error) {
+		if len(returning) > 0 {
+			return r.builder.insert(relation).Values(values).Returning(returning...).One(conn, r.ctx)
+		}
+		return r.builder.insert(relation).Values(values).Exec(conn, r.ctx)
+	})
 }
 
 func (r *Repository) Update(target string, values map[string]interface{}, returning ...string) (string, error) {
-	if len(returning) > 0 {
-		return r.builder.Update(target).SetWherePk(values).Returning(returning...).One(r.conn, r.ctx)
-	}
-	return r.builder.Update(target).SetWherePk(values).Exec(r.conn, r.ctx)
+	return r.run(target, OpUpdate, func(conn PgxConn, relation string) (string, error) {
+		if len(returning) > 0 {
+			return r.builder.update(relation).SetWherePk(values).Returning(returning...).One(conn, r.ctx)
+		}
+		return r.builder.update(relation).SetWherePk(values).Exec(conn, r.ctx)
+	})
 }
 
 func (r *Repository) Delete(target string, values map[string]interface{}, returning ...string) (string, error) {
-	if len(returning) > 0 {
-		return r.builder.Delete(target).Where(values).Returning(returning...).One(r.conn, r.ctx)
-	}
-	return r.builder.Delete(target).Where(values).Exec(r.conn, r.ctx)
+	return r.run(target, OpDelete, func(conn PgxConn, relation string) (string, error) {
+		if len(returning) > 0 {
+			return r.builder.delete(relation).Where(values).Returning(returning...).One(conn, r.ctx)
+		}
+		return r.builder.delete(relation).Where(values).Exec(conn, r.ctx)
+	})
+}
+
+// HardDelete deletes rows of soft delete relation
+func (r *Repository) HardDelete(target string, values map[string]interface{}, returning ...string) (string, error) {
+	return r.run(target, OpDelete, func(conn PgxConn, relation string) (string, error) {
+		if len(returning) > 0 {
+			return r.builder.delete(relation).HardDelete().Where(values).Returning(returning...).One(conn, r.ctx)
+		}
+		return r.builder.delete(relation).HardDelete().Where(values).Exec(conn, r.ctx)
+	})
+}
+
+// Restore reverts soft delete of rows matching values
+func (r *Repository) Restore(target string, values map[string]interface{}, returning ...string) (string, error) {
+	return r.run(target, OpUpdate, func(conn PgxConn, relation string) (string, error) {
+		if len(returning) > 0 {
+			return r.builder.delete(relation).Restore().Where(values).Returning(returning...).One(conn, r.ctx)
+		}
+		return r.builder.delete(relation).Restore().Where(values).Exec(conn, r.ctx)
+	})
+}
+
+// RefreshMaterializedView replaces contents of materialized view, concurrently requires unique index on the view
+func (r *Repository) RefreshMaterializedView(target string, concurrently bool) (string, error) {
+	return r.run(target, OpUpdate, func(conn PgxConn, relation string) (string, error) {
+		return r.builder.RefreshMaterializedView(conn, r.ctx, relation, concurrently)
+	})
+}
+
+// Rpc calls function with named args and returns its result as json, set-returning functions return json array
+func (r *Repository) Rpc(function string, args map[string]interface{}) (string, error) {
+	return r.run(function, OpCall, func(conn PgxConn, function string) (string, error) {
+		return r.builder.call(function, args).Result(conn, r.ctx)
+	})
+}
+
+// ClaimNext locks up to n rows matching where values ordered by orderBy, skipping rows locked by concurrent workers,
+// updates them with set values marking them as claimed, e.g. {"status": "running"}, and returns updated rows
+// as json array, all in a single statement
+func (r *Repository) ClaimNext(target string, where map[string]interface{}, orderBy string, n uint64, set map[string]interface{}) (string, error) {
+	return r.run(target, OpSelect|OpUpdate, func(conn PgxConn, relation string) (string, error) {
+		var pks []string
+		for _, v := range r.builder.ColSchema(relation) {
+			if v.IsPrimaryKey {
+				pks = append(pks, v.ColumnName)
+			}
+		}
+		if len(pks) == 0 {
+			return "", fmt.Errorf("relation %s has no primary key", relation)
+		}
+
+		q := r.builder.query(relation).
+			Select(pks...).
+			Where(where).
+			OrderBy(orderBy).
+			Limit(n).
+			ForUpdate().
+			SkipLocked()
+
+		return r.builder.update(relation).
+			Set(set).
+			WhereIn(pks, q).
+			ReturningAll().
+			All(conn, r.ctx)
+	})
 }
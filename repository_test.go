@@ -89,3 +89,152 @@ func TestRepositoryExec(t *testing.T) {
 	assert.Equal(t, int64(1), gjson.Get(json, "y").Int())
 	assert.Equal(t, nil, err)
 }
+
+func TestRepositoryClaimNext(t *testing.T) {
+	Init(t)
+	ResetTables(t, conn, "test.\"Test2\"")
+
+	repo := pgxjrep.New(builder, conn, ctx)
+
+	for i := 0; i < 3; i++ {
+		_, err := repo.Insert("test.Test2", insert3)
+		assert.Equal(t, nil, err)
+	}
+
+	json, err := repo.ClaimNext("test.Test2", map[string]interface{}{"x": "a"}, "id", 2, map[string]interface{}{"x": "b"})
+	assert.Equal(t, int64(2), gjson.Get(json, "#").Int())
+	assert.Equal(t, "b", gjson.Get(json, "1.x").String())
+	assert.Equal(t, nil, err)
+
+	json, err = repo.ClaimNext("test.Test2", map[string]interface{}{"x": "a"}, "id", 2, map[string]interface{}{"x": "b"})
+	assert.Equal(t, int64(3), gjson.Get(json, "0.id").Int())
+	assert.Equal(t, nil, err)
+
+	json, err = repo.ClaimNext("test.Test2", map[string]interface{}{"x": "a"}, "id", 2, map[string]interface{}{"x": "b"})
+	assert.Equal(t, "[]", json)
+	assert.Equal(t, nil, err)
+}
+
+func TestRepositoryVersion(t *testing.T) {
+	Init(t)
+	ResetTables(t, conn, "test_version")
+	builder.VersionColumn("test_version", "version")
+	defer builder.VersionColumn("test_version", "")
+
+	repo := pgxjrep.New(builder, conn, ctx)
+
+	_, err := repo.Insert("test_version", map[string]interface{}{"name": "a"})
+	assert.Equal(t, nil, err)
+
+	json, etag, err := repo.OneByPkWithETag("test_version", map[string]interface{}{"id": 1})
+	assert.Equal(t, "{\"id\":1,\"name\":\"a\",\"version\":1}", json)
+	assert.Equal(t, "\"1\"", etag)
+	assert.Equal(t, nil, err)
+
+	update := map[string]interface{}{"id": 1, "name": "b", "version": pgxjrep.ParseETag(etag)}
+	json, err = repo.Update("test_version", update)
+	assert.Equal(t, int64(1), gjson.Get(json, "rowsAffected").Int())
+	assert.Equal(t, nil, err)
+
+	_, err = repo.Update("test_version", update)
+	assert.Equal(t, pgxjrep.ErrConflict, err)
+
+	_, err = repo.Update("test_version", update, "id")
+	assert.Equal(t, pgxjrep.ErrConflict, err)
+
+	_, err = builder.Update("test_version").Set(map[string]interface{}{"name": "c"}).Where(map[string]interface{}{"id": 1}).
+		IfMatch(etag).Returning("id").All(conn, ctx)
+	assert.Equal(t, pgxjrep.ErrConflict, err)
+}
+
+func TestRepositorySettings(t *testing.T) {
+	Init(t)
+	ResetTables(t, conn, "test.\"Test2\"")
+
+	settingsCtx := pgxjrep.ContextWithSettings(ctx, map[string]string{"app.user_id": "7"})
+	repo := pgxjrep.New(builder, conn, settingsCtx)
+
+	json, err := repo.Insert("test.Test2", insert3)
+	assert.Equal(t, int64(1), gjson.Get(json, "rowsAffected").Int())
+	assert.Equal(t, nil, err)
+
+	json, err = repo.All("test.Test2")
+	assert.Equal(t, "[{\"id\":1,\"x\":\"a\",\"y\":1,\"z\":true}]", json)
+	assert.Equal(t, nil, err)
+
+	// settings are transaction local
+	setting := new(string)
+	err = conn.QueryRow(ctx, "SELECT current_setting('app.user_id', true)").Scan(setting)
+	assert.Equal(t, "", *setting)
+	assert.Equal(t, nil, err)
+
+	// settings applied in savepoint of caller transaction do not leak into its later statements
+	tx, err := conn.Begin(ctx)
+	assert.Equal(t, nil, err)
+	defer tx.Rollback(ctx)
+
+	_, err = pgxjrep.New(builder, tx, settingsCtx).All("test.Test2")
+	assert.Equal(t, nil, err)
+
+	err = tx.QueryRow(ctx, "SELECT COALESCE(current_setting('app.user_id', true), '')").Scan(setting)
+	assert.Equal(t, "", *setting)
+	assert.Equal(t, nil, err)
+}
+
+func TestRepositoryExpose(t *testing.T) {
+	Init(t)
+	ResetTables(t, conn, "test.\"Test2\"")
+
+	exposed, err := pgxjrep.NewBuilder(conn, ctx)
+	assert.Equal(t, nil, err)
+	exposed.Expose("items", "test.Test2", pgxjrep.OpSelect, pgxjrep.OpInsert)
+
+	repo := pgxjrep.New(exposed, conn, ctx)
+
+	json, err := repo.Insert("items", insert3)
+	assert.Equal(t, int64(1), gjson.Get(json, "rowsAffected").Int())
+	assert.Equal(t, nil, err)
+
+	json, err = repo.All("items")
+	assert.Equal(t, "[{\"id\":1,\"x\":\"a\",\"y\":1,\"z\":true}]", json)
+	assert.Equal(t, nil, err)
+
+	_, err = repo.Delete("items", map[string]interface{}{"id": 1})
+	assert.Equal(t, &pgxjrep.OperationError{Resource: "items", Operation: pgxjrep.OpDelete}, err)
+
+	_, err = repo.All("test.Test2")
+	assert.Equal(t, pgxjrep.ErrNotExposed, err)
+
+	stm, _, err := exposed.Query("items").Select("x").BuildContext(ctx)
+	assert.Equal(t, "SELECT \"X\" AS x FROM test.\"Test2\"", stm)
+	assert.Equal(t, nil, err)
+
+	_, _, err = exposed.Query("test.Test2").BuildContext(ctx)
+	assert.Equal(t, pgxjrep.ErrNotExposed, err)
+
+	_, _, err = exposed.Update("items").Set(map[string]interface{}{"x": "b"}).BuildContext(ctx)
+	assert.Equal(t, &pgxjrep.OperationError{Resource: "items", Operation: pgxjrep.OpUpdate}, err)
+}
+
+func TestRepositoryRefreshMaterializedView(t *testing.T) {
+	Init(t)
+	ResetTables(t, conn, "test_soft")
+
+	repo := pgxjrep.New(builder, conn, ctx)
+
+	_, err := repo.Insert("test_soft", map[string]interface{}{"name": "a"})
+	assert.Equal(t, nil, err)
+
+	_, err = repo.RefreshMaterializedView("test_matview", false)
+	assert.Equal(t, nil, err)
+
+	json, err := repo.All("test_matview")
+	assert.Equal(t, "[{\"id\":1,\"name\":\"a\"}]", json)
+	assert.Equal(t, nil, err)
+
+	_, err = repo.RefreshMaterializedView("test_matview", true)
+	assert.Equal(t, nil, err)
+
+	_, err = repo.RefreshMaterializedView("test_soft", false)
+	assert.EqualError(t, err, "relation test_soft of kind r is not a materialized view")
+}
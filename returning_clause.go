@@ -1,18 +1,51 @@
 package pgxjrep
 
-import "strings"
+import (
+	"context"
+	"strings"
+)
 
 type returningClause struct {
-	schema *DbSchema
-	target string
-	cols   []string
+	schema    *DbSchema
+	target    string
+	cols      []string
+	all       bool
+	nested    bool
+	qualified bool
+	perms     *permissions
 }
 
-func (c *returningClause) build() string {
+func (c *returningClause) build(ctx context.Context) string {
+	if c.all {
+		c.cols = c.perms.readColumns(ctx, c.target, nil)
+		if len(c.cols) == 0 {
+			for _, v := range c.schema.ColSchema(c.target) {
+				if !v.IsHidden {
+					c.cols = append(c.cols, v.ColumnName)
+				}
+			}
+		}
+	} else if len(c.cols) > 0 {
+		c.perms.readColumns(ctx, c.target, c.cols)
+	}
+
+	if len(c.cols) > 0 && c.nested {
+		var rets []string
+		for _, v := range c.schema.ResolveColumns(c.target, c.cols) {
+			rets = append(rets, c.column(v.DbName))
+		}
+
+		return " RETURNING " + strings.Join(rets, ", ")
+	}
+
 	if len(c.cols) > 0 {
 		var rets []string
 		for _, v := range c.schema.ResolveColumns(c.target, c.cols) {
-			rets = append(rets, c.schema.SingleQuote(v.JsonName)+", "+c.schema.Quote(v.DbName))
+			if v.isRange() {
+				rets = append(rets, c.schema.SingleQuote(v.JsonName)+", "+c.schema.rangeJson(c.column(v.DbName)))
+			} else {
+				rets = append(rets, c.schema.SingleQuote(v.JsonName)+", "+c.column(v.DbName))
+			}
 		}
 
 		return " RETURNING json_build_object(" + strings.Join(rets, ", ") + ")"
@@ -20,3 +53,12 @@ func (c *returningClause) build() string {
 
 	return ""
 }
+
+// column qualifies column with target when statement joins other relations
+func (c *returningClause) column(name string) string {
+	if c.qualified {
+		return c.schema.QuoteRelation(c.target) + "." + c.schema.Quote(name)
+	}
+
+	return c.schema.Quote(name)
+}
@@ -3,53 +3,94 @@ package pgxjrep
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"github.com/iancoleman/strcase"
 	"github.com/sirupsen/logrus"
 	"os"
-	"regexp"
+	"sort"
 	"strings"
+	"sync"
 )
 
 const PublicSchema = "public"
 
 type DbSchema struct {
-	ToDbCase   func(input string) string
+	ToDbCase func(input string) string
+	// Deprecated: use SetJsonCase, columns loaded with schema are not renamed when ToJsonCase is assigned directly
 	ToJsonCase func(input string) string
 	colSchema  map[string]map[string][]ColumnSchema
+	// mu guards json name mapping, rebuilt by SetJsonName and SetJsonCase while statements are built
+	mu         sync.RWMutex
 	colMap     map[string]map[string]map[string]bool
-	keywords   []string
+	jsonNames  map[string]map[string]map[string]string
+	overrides  map[string]map[string]string
+	searchPath []string
+	extensions map[string]bool
+	functions  map[string]map[string][]FunctionSchema
+	keywords   map[string]struct{}
+	plans      *planCache
 }
 
 type ColumnSchema struct {
-	SchemaName             string   `json:"schemaName"`
-	RelationName           string   `json:"relationName"`
-	ColumnName             string   `json:"columnName"`
-	Position               int16    `json:"position"`
-	TypeOid                int64    `json:"typeOid"`
-	DataType               string   `json:"dataType"`
-	TypeType               string   `json:"typeType"`
-	Size                   int      `json:"size"`
-	Modifier               int      `json:"modifier"`
-	Dimension              int      `json:"dimension"`
-	CharacterMaximumLength *int     `json:"characterMaximumLength"`
-	NumericPrecision       *int     `json:"numericPrecision"`
-	NumericScale           *int     `json:"numericScale"`
-	EnumValues             []string `json:"enumValues"`
-	DefaultValue           string   `json:"defaultValue"`
-	IsNotNull              bool     `json:"isNotNull"`
-	IsGenerated            bool     `json:"isGenerated"`
-	IsPrimaryKey           bool     `json:"isPrimaryKey"`
-	IsRequired             bool     `json:"isRequired"`
-	IsReadonly             bool     `json:"isReadonly"`
-	ColumnComment          bool     `json:"columnComment"`
+	SchemaName             string    `json:"schemaName"`
+	RelationName           string    `json:"relationName"`
+	ColumnName             string    `json:"columnName"`
+	Position               int16     `json:"position"`
+	TypeOid                int64     `json:"typeOid"`
+	DataType               string    `json:"dataType"`
+	TypeType               string    `json:"typeType"`
+	BaseTypeName           string    `json:"baseTypeName"`
+	Class                  TypeClass `json:"class"`
+	Size                   int       `json:"size"`
+	Modifier               int       `json:"modifier"`
+	Dimension              int       `json:"dimension"`
+	CharacterMaximumLength *int      `json:"characterMaximumLength"`
+	NumericPrecision       *int      `json:"numericPrecision"`
+	NumericScale           *int      `json:"numericScale"`
+	EnumValues             []string  `json:"enumValues"`
+	DefaultValue           string    `json:"defaultValue"`
+	IsNotNull              bool      `json:"isNotNull"`
+	IsGenerated            bool      `json:"isGenerated"`
+	IsPrimaryKey           bool      `json:"isPrimaryKey"`
+	IsRequired             bool      `json:"isRequired"`
+	IsReadonly             bool      `json:"isReadonly"`
+	IsHidden               bool      `json:"isHidden"`
+	Format                 string    `json:"format"`
+	ColumnComment          string    `json:"columnComment"`
+	RangeSubtype           string    `json:"rangeSubtype"`
+	RelationKind           string    `json:"relationKind"`
+	RelationUpdatable      int       `json:"relationUpdatable"`
+	readonly               bool
 }
 
 type ColumnData struct {
-	DbName   string
-	JsonName string
-	Value    interface{}
-	IsString bool
-	IsPk     bool
+	DbName    string
+	JsonName  string
+	Value     interface{}
+	IsString  bool
+	IsPk      bool
+	qualifier string
+	relation  string
+	dataType  string
+	typeOid   int64
+	class     TypeClass
+	dimension int
+	subtype   string
+	enum      []string
+	path      []string
+	hidden    bool
+	readonly  bool
+	format    string
+}
+
+// AmbiguousRelationError is returned when unqualified relation name exists in multiple schemas of search path
+type AmbiguousRelationError struct {
+	Relation string
+	Schemas  []string
+}
+
+func (e *AmbiguousRelationError) Error() string {
+	return fmt.Sprintf("relation %s is ambiguous, found in schemas %s", e.Relation, strings.Join(e.Schemas, ", "))
 }
 
 type keywordSchema struct {
@@ -69,7 +110,11 @@ func NewSchema(conn PgxConn, ctx context.Context) (*DbSchema, error) {
 		ToDbCase:   strcase.ToSnake,
 		ToJsonCase: strcase.ToLowerCamel,
 		colSchema:  make(map[string]map[string][]ColumnSchema),
-		colMap:     make(map[string]map[string]map[string]bool),
+		overrides:  make(map[string]map[string]string),
+		extensions: make(map[string]bool),
+		functions:  make(map[string]map[string][]FunctionSchema),
+		keywords:   make(map[string]struct{}),
+		plans:      newPlanCache(),
 	}
 
 	sql := `
@@ -82,6 +127,7 @@ func NewSchema(conn PgxConn, ctx context.Context) (*DbSchema, error) {
 				COALESCE(td.oid, tb.oid, t.oid)::bigint                                                                     AS "typeOid",
 				format_type(atttypid, NULL::integer)                                                                        AS "dataType",
 				COALESCE(td.typtype, tb.typtype, t.typtype)::text                                                           AS "typeType",
+				COALESCE(td.typname, tb.typname, t.typname)::text                                                           AS "baseTypeName",
 				a.attlen::int                                                                                               AS "size",
 				a.atttypmod::int                                                                                            AS "modifier",
 				COALESCE(NULLIF(a.attndims, 0), NULLIF(t.typndims, 0), (t.typcategory='A')::int)                            AS "dimension",
@@ -144,7 +190,10 @@ func NewSchema(conn PgxConn, ctx context.Context) (*DbSchema, error) {
 						OR (c.relkind = ANY (ARRAY ['v', 'f'])) AND pg_column_is_updatable(c.oid::regclass, a.attnum, false) THEN false
 					ELSE true
 					END::bool                                                                                               AS "isReadonly",
-				pg_catalog.col_description(c.oid, a.attnum)                                                                 AS "columnComment"
+				pg_catalog.col_description(c.oid, a.attnum)                                                                 AS "columnComment",
+				(SELECT format_type(r.rngsubtype, NULL) FROM pg_range r WHERE r.rngtypid = COALESCE(td.oid, tb.oid, t.oid)) AS "rangeSubtype",
+				c.relkind::text                                                                                             AS "relationKind",
+				pg_relation_is_updatable(c.oid::regclass, true)                                                             AS "relationUpdatable"
 			FROM
 				pg_class c
 				LEFT JOIN pg_attribute a ON a.attrelid = c.oid
@@ -179,23 +228,17 @@ func NewSchema(conn PgxConn, ctx context.Context) (*DbSchema, error) {
 	}
 
 	for _, v := range res {
+		v.annotate()
+		v.Class = classify(v.TypeOid, v.TypeType, v.BaseTypeName)
 		if _, ok := dbSchema.colSchema[v.SchemaName]; !ok {
 			dbSchema.colSchema[v.SchemaName] = make(map[string][]ColumnSchema)
-			dbSchema.colMap[v.SchemaName] = make(map[string]map[string]bool)
-		}
-
-		if _, ok := dbSchema.colSchema[v.SchemaName][v.RelationName]; !ok {
-			dbSchema.colMap[v.SchemaName][v.RelationName] = make(map[string]bool)
 		}
 
 		dbSchema.colSchema[v.SchemaName][v.RelationName] = append(dbSchema.colSchema[v.SchemaName][v.RelationName], v)
-
-		dbSchema.colMap[v.SchemaName][v.RelationName][v.ColumnName] = true
-		jsonName := dbSchema.ToJsonCase(v.ColumnName)
-		if jsonName != v.ColumnName {
-			dbSchema.colMap[v.SchemaName][v.RelationName][jsonName] = false
-		}
 	}
+	dbSchema.mu.Lock()
+	dbSchema.mapColumns()
+	dbSchema.mu.Unlock()
 
 	//keywords
 	sql = "SELECT json_agg(t) FROM (SELECT word FROM pg_get_keywords() WHERE catcode != 'U' ORDER BY 1) t"
@@ -212,20 +255,150 @@ func NewSchema(conn PgxConn, ctx context.Context) (*DbSchema, error) {
 	}
 
 	for _, v := range ks {
-		dbSchema.keywords = append(dbSchema.keywords, v.Word)
+		dbSchema.keywords[v.Word] = struct{}{}
+	}
+
+	err = dbSchema.loadFunctions(conn, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	//search path
+	sql = "SELECT COALESCE(array_to_json(current_schemas(false)), '[]')::text"
+	jsn = new(string)
+	err = conn.QueryRow(ctx, sql).Scan(jsn)
+	if err != nil {
+		return nil, err
+	}
+
+	var searchPath []string
+	err = json.Unmarshal([]byte(*jsn), &searchPath)
+	if err != nil {
+		return nil, err
+	}
+	dbSchema.SetSearchPath(searchPath...)
+
+	//extensions
+	sql = "SELECT COALESCE(json_agg(extname), '[]')::text FROM pg_extension"
+	jsn = new(string)
+	err = conn.QueryRow(ctx, sql).Scan(jsn)
+	if err != nil {
+		return nil, err
+	}
+
+	var extensions []string
+	err = json.Unmarshal([]byte(*jsn), &extensions)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range extensions {
+		dbSchema.extensions[v] = true
 	}
 
 	return dbSchema, nil
 }
 
+// HasExtension reports whether extension was installed in database when schema was loaded
+func (s *DbSchema) HasExtension(name string) bool {
+	return s.extensions[name]
+}
+
+// SetSearchPath sets schemas unqualified relation names are resolved in, in order, defaults to search_path
+// of connection schema is loaded with, set it to the search_path of connections statements are executed on
+func (s *DbSchema) SetSearchPath(schemas ...string) {
+	if len(schemas) == 0 {
+		schemas = []string{PublicSchema}
+	}
+	s.searchPath = schemas
+	s.plans.reset()
+}
+
+// SearchPath returns schemas unqualified relation names are resolved in
+func (s *DbSchema) SearchPath() []string {
+	return s.searchPath
+}
+
+// mapColumns maps db and json names of all columns, json name is taken from SetJsonName override,
+// "@json:name" column comment annotation or SetJsonCase mapping, in that order, callers hold write lock of mu
+func (s *DbSchema) mapColumns() {
+	colMap := make(map[string]map[string]map[string]bool)
+	jsonNames := make(map[string]map[string]map[string]string)
+	for sch, rels := range s.colSchema {
+		colMap[sch] = make(map[string]map[string]bool)
+		jsonNames[sch] = make(map[string]map[string]string)
+		for rel, cols := range rels {
+			colMap[sch][rel] = make(map[string]bool)
+			jsonNames[sch][rel] = make(map[string]string)
+			for _, v := range cols {
+				colMap[sch][rel][v.ColumnName] = true
+			}
+			for _, v := range cols {
+				jsonName, ok := s.overrides[sch+"."+rel][v.ColumnName]
+				if !ok {
+					jsonName, ok = annotation(v.ColumnComment, "json")
+				}
+				if !ok || jsonName == "" {
+					jsonName = s.ToJsonCase(v.ColumnName)
+				}
+				jsonNames[sch][rel][v.ColumnName] = jsonName
+				if _, ok := colMap[sch][rel][jsonName]; !ok {
+					colMap[sch][rel][jsonName] = false
+				}
+			}
+		}
+	}
+
+	s.colMap = colMap
+	s.jsonNames = jsonNames
+	s.plans.reset()
+}
+
+// SetJsonName overrides json name of relation column, set it up before statements are built
+func (s *DbSchema) SetJsonName(relation string, column string, name string) {
+	sch, rel := s.resolveNames(relation)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.colMap[sch][rel][column] {
+		log.Panicln("column not found: ", sch, rel, column)
+	}
+
+	if _, ok := s.overrides[sch+"."+rel]; !ok {
+		s.overrides[sch+"."+rel] = make(map[string]string)
+	}
+	s.overrides[sch+"."+rel][column] = name
+	s.mapColumns()
+}
+
+// SetJsonCase replaces case mapping of json names, lower camel case by default,
+// columns without override are renamed by toJson
+func (s *DbSchema) SetJsonCase(toJson func(input string) string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ToJsonCase = toJson
+	s.mapColumns()
+}
+
+// JsonName returns json name of relation column
+func (s *DbSchema) JsonName(relation string, column string) string {
+	sch, rel := s.resolveNames(relation)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.jsonNames[sch][rel][column]
+}
+
 func (s *DbSchema) ColSchema(relation string) []ColumnSchema {
 	sch, rel := s.resolveNames(relation)
 
 	return s.colSchema[sch][rel]
 }
 
+// ColMap returns db and json names of relation columns, json names map to false unless they are db names too.
+// Returned map is replaced, never modified, when json names change
 func (s *DbSchema) ColMap(relation string) map[string]bool {
 	sch, rel := s.resolveNames(relation)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
 	return s.colMap[sch][rel]
 }
@@ -240,70 +413,206 @@ func (s *DbSchema) ResolveColumns(relation string, columns []string) []ColumnDat
 }
 
 func (s *DbSchema) ResolveColumnMap(relation string, values map[string]interface{}) []ColumnData {
-	var colVals []ColumnData
-	var isChar = func(term string) bool {
-		for _, v := range []string{"varchar", "char", "text"} {
-			if term == v {
-				return true
-			}
-		}
-		return false
+	plan := s.columnPlan(relation, values)
+	if len(plan.unresolved) > 0 {
+		log.Warningf("Relation %s does not contain columns: "+strings.Join(plan.unresolved, ", "), relation)
 	}
 
-	cols := s.ColSchema(relation)
-	for _, col := range cols {
-		cd := ColumnData{
-			DbName:   col.ColumnName,
-			JsonName: s.ToJsonCase(col.ColumnName),
-			Value:    nil,
-			IsString: isChar(col.DataType),
-			IsPk:     col.IsPrimaryKey,
-		}
+	colVals := make([]ColumnData, len(plan.cols))
+	for i, cd := range plan.cols {
+		cd.Value = values[plan.keys[i]]
+		colVals[i] = cd
+	}
+
+	return colVals
+}
 
-		if val, ok := values[cd.DbName]; ok {
-			cd.Value = val
-			colVals = append(colVals, cd)
-		} else if val, ok = values[cd.JsonName]; ok {
-			cd.Value = val
-			colVals = append(colVals, cd)
+func (s *DbSchema) columnPlan(relation string, values map[string]interface{}) *columnPlan {
+	key := columnPlanKey(relation, values)
+	cached, generation, ok := s.plans.columnPlan(key)
+	if ok {
+		return cached
+	}
+
+	plan := &columnPlan{}
+	sch, rel := s.resolveNames(relation)
+	for _, col := range s.colSchema[sch][rel] {
+		cd := s.columnData(relation, col)
+
+		if _, ok := values[cd.DbName]; ok {
+			plan.cols = append(plan.cols, cd)
+			plan.keys = append(plan.keys, cd.DbName)
+		} else if _, ok = values[cd.JsonName]; ok {
+			plan.cols = append(plan.cols, cd)
+			plan.keys = append(plan.keys, cd.JsonName)
 		}
 	}
 
-	var unresolvedColumns []string
 	colMap := s.ColMap(relation)
 	for k := range values {
 		if _, ok := colMap[k]; !ok {
-			unresolvedColumns = append(unresolvedColumns, k)
+			plan.unresolved = append(plan.unresolved, k)
 		}
 	}
-	if len(unresolvedColumns) > 0 {
-		log.Warningf("Relation %s does not contain columns: "+strings.Join(unresolvedColumns, ", "), relation)
+	sort.Strings(plan.unresolved)
+
+	// keys come from client input, unresolved ones are not memoized
+	if len(plan.unresolved) == 0 {
+		s.plans.storeColumnPlan(key, plan, generation)
+	}
+
+	return plan
+}
+
+// columnData returns column of relation classified for clause builders, strings are scalar string class columns
+func (s *DbSchema) columnData(relation string, col ColumnSchema) ColumnData {
+	return ColumnData{
+		DbName:    col.ColumnName,
+		JsonName:  s.JsonName(relation, col.ColumnName),
+		Value:     nil,
+		IsString:  col.Class == ClassString && col.Dimension == 0,
+		IsPk:      col.IsPrimaryKey,
+		relation:  relation,
+		dataType:  col.DataType,
+		typeOid:   col.TypeOid,
+		class:     col.Class,
+		dimension: col.Dimension,
+		subtype:   col.RangeSubtype,
+		enum:      col.EnumValues,
+		hidden:    col.IsHidden,
+		readonly:  col.readonly,
+		format:    col.Format,
 	}
+}
 
-	return colVals
+// SelectList returns quoted columns aliased to json names, all relation columns but hidden ones when columns are omitted
+func (s *DbSchema) SelectList(relation string, columns []string) string {
+	return s.selectList(relation, columns, true)
 }
 
-func (s *DbSchema) SingleQuote(value string) string {
-	return "'" + value + "'"
+// ColumnList returns quoted columns without json aliases, all relation columns but hidden ones when columns are omitted
+func (s *DbSchema) ColumnList(relation string, columns []string) string {
+	return s.selectList(relation, columns, false)
 }
 
-func (s *DbSchema) Quote(value string) string {
-	if strings.ContainsAny(value, "\"") {
-		return value
+func (s *DbSchema) selectList(relation string, columns []string, aliased bool) string {
+	key := selectPlanKey(relation, columns, aliased)
+	cached, generation, ok := s.plans.selectList(key)
+	if ok {
+		return cached
 	}
 
-	match, _ := regexp.MatchString("[A-Z]+", value)
-	if match {
-		return "\"" + value + "\""
+	var cols []string
+	selected, paths, resolved := s.selectedColumns(relation, columns)
+	for _, v := range selected {
+		cols = append(cols, s.selectColumn(v, aliased))
+	}
+	// json paths are aliased to their last key
+	for _, v := range paths {
+		col, path, _ := s.jsonPath(relation, v)
+		cols = append(cols, s.Quote(col.DbName)+" #> "+s.pathLiteral(path)+" AS "+s.pathAlias(path))
 	}
 
-	for _, v := range s.keywords {
-		if v == value {
-			return "\"" + value + "\""
+	list := strings.Join(cols, ", ")
+	if resolved {
+		s.plans.storeSelectList(key, list, generation)
+	}
+
+	return list
+}
+
+// outputList returns select list of statement wrapping SelectList, converting range columns to json objects,
+// empty when no range column is selected
+func (s *DbSchema) outputList(relation string, columns []string) string {
+	key := "\x02" + selectPlanKey(relation, columns, true)
+	cached, generation, ok := s.plans.selectList(key)
+	if ok {
+		return cached
+	}
+
+	var cols []string
+	var ranges bool
+	selected, paths, resolved := s.selectedColumns(relation, columns)
+	for _, v := range selected {
+		if v.isRange() {
+			ranges = true
+			cols = append(cols, s.rangeJson(s.Quote(v.JsonName))+" AS "+s.Quote(v.JsonName))
+		} else {
+			cols = append(cols, s.Quote(v.JsonName))
 		}
 	}
+	for _, v := range paths {
+		_, path, _ := s.jsonPath(relation, v)
+		cols = append(cols, s.pathAlias(path))
+	}
+
+	var list string
+	if ranges {
+		list = strings.Join(cols, ", ")
+	}
+	if resolved {
+		s.plans.storeSelectList(key, list, generation)
+	}
+
+	return list
+}
+
+// selectedColumns resolves columns and json paths of select list, all relation columns but hidden ones when columns are omitted,
+// resolved is false when some of columns are not found
+func (s *DbSchema) selectedColumns(relation string, columns []string) (cols []ColumnData, paths []string, resolved bool) {
+	if len(columns) == 0 {
+		for _, v := range s.ColSchema(relation) {
+			if v.IsHidden {
+				continue
+			}
+			cols = append(cols, s.columnData(relation, v))
+		}
+
+		return cols, nil, true
+	}
+
+	var names []string
+	for _, v := range columns {
+		if _, _, ok := s.jsonPath(relation, v); ok {
+			paths = append(paths, v)
+		} else {
+			names = append(names, v)
+		}
+	}
+	cols = s.ResolveColumns(relation, names)
+
+	return cols, paths, len(cols) == len(names)
+}
+
+// pathAlias returns quoted last key of json path
+func (s *DbSchema) pathAlias(path []string) string {
+	return "\"" + strings.Replace(path[len(path)-1], "\"", "\"\"", -1) + "\""
+}
+
+// selectColumn returns column aliased to json name, range columns keep their type so they can be ordered and compared,
+// they are converted to json objects by outputList
+func (s *DbSchema) selectColumn(v ColumnData, aliased bool) string {
+	if !aliased || v.DbName == v.JsonName {
+		return s.Quote(v.DbName)
+	}
+
+	return s.Quote(v.DbName) + " AS " + s.Quote(v.JsonName)
+}
+
+func (s *DbSchema) SingleQuote(value string) string {
+	return "'" + value + "'"
+}
 
-	return value
+func (s *DbSchema) Quote(value string) string {
+	quoted := value
+	if !strings.ContainsAny(value, "\"") {
+		if strings.IndexFunc(value, func(r rune) bool { return r >= 'A' && r <= 'Z' }) >= 0 {
+			quoted = "\"" + value + "\""
+		} else if _, ok := s.keywords[value]; ok {
+			quoted = "\"" + value + "\""
+		}
+	}
+	return quoted
 }
 
 func (s *DbSchema) UnQuote(value string) string {
@@ -317,34 +626,73 @@ func (s *DbSchema) UnQuote(value string) string {
 func (s *DbSchema) QuoteRelation(relation string) string {
 	sch, rel := s.resolveNames(relation)
 
+	// public relation is emitted unqualified only when server resolves its name to public through search path
 	if sch == PublicSchema {
-		return s.Quote(rel)
+		for _, v := range s.searchPath {
+			if _, ok := s.colSchema[v][rel]; ok {
+				if v == sch {
+					return s.Quote(rel)
+				}
+				break
+			}
+		}
 	}
 
 	return s.Quote(sch) + "." + s.Quote(rel)
 }
 
-func (s *DbSchema) resolveNames(relation string) (string, string) {
-	var sch, rel string
-	names := strings.Split(relation, ".")
-
-	namesLen := len(names)
-	if namesLen == 2 {
-		sch = names[0]
-		rel = names[1]
-	} else if namesLen == 1 {
-		sch = PublicSchema
-		rel = names[0]
-	} else {
-		log.Panicln("invalid relation name: ", relation)
+// ResolveRelation returns schema qualified relation name, unqualified name is looked up in search path
+// and *AmbiguousRelationError is returned when it exists in more than one of its schemas
+func (s *DbSchema) ResolveRelation(relation string) (string, error) {
+	sch, rel, err := s.lookup(relation)
+	if err != nil {
+		return "", err
 	}
 
-	if _, ok := s.colSchema[sch]; !ok {
-		log.Panicln("schema not found: ", sch)
+	return sch + "." + rel, nil
+}
+
+// resolveNames splits relation into schema and name, ambiguous names depend on search path configured
+// in database and fail the build with *AmbiguousRelationError, names not found are programmer errors
+func (s *DbSchema) resolveNames(relation string) (string, string) {
+	sch, rel, err := s.lookup(relation)
+	if _, ok := err.(*AmbiguousRelationError); ok {
+		throw(err)
 	}
-	if _, ok := s.colSchema[sch][rel]; !ok {
-		log.Panicln("relation not found: ", sch, rel)
+	if err != nil {
+		log.Panicln(err)
 	}
 
 	return sch, rel
 }
+
+func (s *DbSchema) lookup(relation string) (string, string, error) {
+	names := strings.Split(relation, ".")
+
+	switch len(names) {
+	case 2:
+		if _, ok := s.colSchema[names[0]]; !ok {
+			return "", "", fmt.Errorf("schema not found: %s", names[0])
+		}
+		if _, ok := s.colSchema[names[0]][names[1]]; !ok {
+			return "", "", fmt.Errorf("relation not found: %s", relation)
+		}
+		return names[0], names[1], nil
+	case 1:
+		var schemas []string
+		for _, v := range s.searchPath {
+			if _, ok := s.colSchema[v][relation]; ok {
+				schemas = append(schemas, v)
+			}
+		}
+		if len(schemas) == 0 {
+			return "", "", fmt.Errorf("relation not found in search path %s: %s", strings.Join(s.searchPath, ", "), relation)
+		}
+		if len(schemas) > 1 {
+			return "", "", &AmbiguousRelationError{Relation: relation, Schemas: schemas}
+		}
+		return schemas[0], relation, nil
+	}
+
+	return "", "", fmt.Errorf("invalid relation name: %s", relation)
+}
@@ -1,6 +1,8 @@
 package pgxjrep_test
 
 import (
+	"github.com/divilla/pgxjrep"
+	"github.com/iancoleman/strcase"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
@@ -16,4 +18,167 @@ func TestSchema(t *testing.T) {
 
 	assert.Equal(t, "\"acaXac\"", builder.Quote("acaXac"))
 	assert.Equal(t, "\"cast\"", builder.Quote("cast"))
+	assert.Equal(t, "\"cast\"", builder.Quote("cast"))
+	assert.Equal(t, "a_a", builder.Quote("a_a"))
+
+	assert.Equal(t, "a_a AS \"aA\", \"b_B\" AS \"bB\"", builder.SelectList("test1", []string{"aA", "b_B"}))
+	assert.Equal(t, "a_a AS \"aA\", \"b_B\" AS \"bB\"", builder.SelectList("test1", []string{"aA", "b_B"}))
+
+	colData := builder.ResolveColumnMap("test1", where2)
+	assert.Equal(t, 3, len(colData))
+	assert.Equal(t, "a", colData[0].Value)
+	colData = builder.ResolveColumnMap("test1", map[string]interface{}{"aA": "b", "bB": 2, "ccCc": 3})
+	assert.Equal(t, "b", colData[0].Value)
+	assert.Equal(t, 3, colData[2].Value)
+}
+
+func TestSchemaJsonName(t *testing.T) {
+	Init(t)
+
+	assert.Equal(t, "active", builder.JsonName("test_annotated", "is_active"))
+	assert.Equal(t, "id, is_active AS active, email, code", builder.SelectList("test_annotated", nil))
+
+	colData := builder.ResolveColumnMap("test_annotated", map[string]interface{}{"active": false})
+	assert.Equal(t, "is_active", colData[0].DbName)
+
+	builder.SetJsonName("test1", "b_B", "bigB")
+	defer builder.SetJsonName("test1", "b_B", "bB")
+
+	assert.Equal(t, "a_a AS \"aA\", \"b_B\" AS \"bigB\"", builder.SelectList("test1", []string{"aA", "bigB"}))
+
+	stm, argsOut := builder.Query("test1").Select("bigB").Filter(map[string]interface{}{"bigB": 1}).Build()
+	assert.Equal(t, "SELECT \"b_B\" AS \"bigB\" FROM test1 WHERE \"b_B\" = $1", stm)
+	assert.Equal(t, append(args, 1), argsOut)
+
+	stm, _ = builder.Update("test1").Set(map[string]interface{}{"bigB": 1}).Returning("bigB").Build()
+	assert.Equal(t, "UPDATE test1 SET \"b_B\" = $1 RETURNING json_build_object('bigB', \"b_B\")", stm)
+}
+
+func TestSchemaAnnotations(t *testing.T) {
+	Init(t)
+
+	cols := builder.ColSchema("test_annotated")
+	assert.Equal(t, "email", cols[2].Format)
+	assert.Equal(t, true, cols[3].IsHidden)
+	assert.Equal(t, true, cols[4].IsReadonly)
+	assert.Equal(t, "Assigned by trigger @readonly", cols[4].ColumnComment)
+
+	stm, _, err := builder.Query("test_annotated").BuildContext(ctx)
+	assert.Equal(t, "SELECT id, is_active AS active, email, code FROM test_annotated", stm)
+	assert.Equal(t, nil, err)
+
+	_, _, err = builder.Query("test_annotated").Select("secret").BuildContext(ctx)
+	assert.Equal(t, &pgxjrep.PermissionError{Relation: "test_annotated", Column: "secret"}, err)
+
+	_, _, err = builder.Query("test_annotated").Where(map[string]interface{}{"secret": "a"}).BuildContext(ctx)
+	assert.Equal(t, &pgxjrep.PermissionError{Relation: "test_annotated", Column: "secret"}, err)
+
+	_, _, err = builder.Insert("test_annotated").Values(map[string]interface{}{"code": "a"}).BuildContext(ctx)
+	assert.Equal(t, &pgxjrep.PermissionError{Relation: "test_annotated", Column: "code", Write: true}, err)
+
+	_, _, err = builder.Insert("test_annotated").Values(map[string]interface{}{"email": "a"}).BuildContext(ctx)
+	assert.Equal(t, &pgxjrep.FormatError{Relation: "test_annotated", Column: "email", Format: "email"}, err)
+
+	stm, _, err = builder.Insert("test_annotated").Values(map[string]interface{}{"email": "a@b.com", "secret": "s"}).BuildContext(ctx)
+	assert.Equal(t, "INSERT INTO test_annotated (email, secret) VALUES ($1, $2)", stm)
+	assert.Equal(t, nil, err)
+}
+
+func TestSchemaSearchPath(t *testing.T) {
+	Init(t)
+	assert.Equal(t, []string{"public"}, builder.SearchPath())
+
+	builder.SetSearchPath("test", "public")
+	defer builder.SetSearchPath("public")
+
+	assert.Equal(t, "test.\"Test2\"", builder.QuoteRelation("Test2"))
+	assert.Equal(t, "test.\"Test2\"", builder.QuoteRelation("test.Test2"))
+	assert.Equal(t, "public.test1", builder.QuoteRelation("public.test1"))
+	assert.Equal(t, "test_soft", builder.QuoteRelation("test_soft"))
+
+	rel, err := builder.ResolveRelation("Test2")
+	assert.Equal(t, "test.Test2", rel)
+	assert.Equal(t, nil, err)
+
+	_, err = builder.ResolveRelation("test1")
+	assert.Equal(t, &pgxjrep.AmbiguousRelationError{Relation: "test1", Schemas: []string{"test", "public"}}, err)
+
+	_, _, err = builder.Query("test1").Where(pk1).BuildContext(ctx)
+	assert.Equal(t, &pgxjrep.AmbiguousRelationError{Relation: "test1", Schemas: []string{"test", "public"}}, err)
+
+	_, err = pgxjrep.New(builder, conn, ctx).Update("test1", map[string]interface{}{"id": 1, "aA": "a"})
+	assert.Equal(t, &pgxjrep.AmbiguousRelationError{Relation: "test1", Schemas: []string{"test", "public"}}, err)
+
+	stm, _ := builder.Query("Test2").Select("x").Build()
+	assert.Equal(t, "SELECT \"X\" AS x FROM test.\"Test2\"", stm)
+
+	stm, _ = builder.Query("public.test1").Select("id").Build()
+	assert.Equal(t, "SELECT id FROM public.test1", stm)
+}
+
+func TestSchemaRelation(t *testing.T) {
+	Init(t)
+
+	rel := builder.RelationSchema("test1")
+	assert.Equal(t, pgxjrep.RelationTable, rel.Kind)
+	assert.Equal(t, true, rel.IsInsertable && rel.IsUpdatable && rel.IsDeletable)
+
+	rel = builder.RelationSchema("test_matview")
+	assert.Equal(t, pgxjrep.RelationMaterializedView, rel.Kind)
+	assert.Equal(t, false, rel.IsInsertable || rel.IsUpdatable || rel.IsDeletable)
+
+	_, _, err := builder.Insert("test_matview").Values(map[string]interface{}{"name": "a"}).BuildContext(ctx)
+	assert.Equal(t, &pgxjrep.NotUpdatableError{Relation: "test_matview", Kind: "m", Operation: pgxjrep.OpInsert}, err)
+
+	_, _, err = builder.Delete("test_matview").Where(pk1).BuildContext(ctx)
+	assert.Equal(t, &pgxjrep.NotUpdatableError{Relation: "test_matview", Kind: "m", Operation: pgxjrep.OpDelete}, err)
+}
+
+func TestSchemaTypes(t *testing.T) {
+	Init(t)
+
+	cols := builder.ColSchema("test_types")
+	assert.Equal(t, pgxjrep.ClassNumber, cols[0].Class)
+	assert.Equal(t, pgxjrep.ClassEnum, cols[1].Class)
+	assert.Equal(t, []string{"draft", "published"}, cols[1].EnumValues)
+	assert.Equal(t, pgxjrep.ClassString, cols[2].Class)
+	assert.Equal(t, pgxjrep.ClassString, cols[3].Class)
+	assert.Equal(t, pgxjrep.ClassString, cols[4].Class)
+
+	stm, args, err := builder.Query("test_types").Select("id").Filter(map[string]interface{}{
+		"status": "draft", "label": "a", "email": "b", "code": "c",
+	}).BuildContext(ctx)
+	assert.Equal(t, "SELECT id FROM test_types WHERE status = $1::test_status AND label ILIKE $2 AND email ILIKE $3 AND code ILIKE $4", stm)
+	assert.Equal(t, []interface{}{"draft", "a%", "b%", "c%"}, args)
+	assert.Equal(t, nil, err)
+
+	_, _, err = builder.Query("test_types").Where(map[string]interface{}{"status": "archived"}).BuildContext(ctx)
+	assert.EqualError(t, err, "column status of relation test_types must be one of draft, published, got archived")
+
+	_, _, err = builder.Insert("test_types").Values(map[string]interface{}{"status": 1}).BuildContext(ctx)
+	assert.Equal(t, &pgxjrep.EnumError{Relation: "test_types", Column: "status", Value: 1, Labels: []string{"draft", "published"}}, err)
+}
+
+func TestSchemaJsonCase(t *testing.T) {
+	Init(t)
+	local, err := pgxjrep.NewBuilder(conn, ctx)
+	assert.Equal(t, nil, err)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			local.Query("test1").Build()
+		}
+	}()
+	local.SetJsonCase(strcase.ToSnake)
+	<-done
+
+	assert.Equal(t, "b_b", local.JsonName("test1", "b_B"))
+	stm, _ := local.Query("test1").Select("b_b").Build()
+	assert.Equal(t, "SELECT \"b_B\" AS b_b FROM test1", stm)
+
+	// plans memoized by builds racing with SetJsonCase are not kept
+	stm, _ = local.Query("test1").Build()
+	assert.Equal(t, "SELECT id, a_a, \"b_B\" AS b_b, cc_cc FROM test1", stm)
 }
@@ -0,0 +1,79 @@
+package pgxjrep
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// SearchKey is full-text search operator key, its value is a websearch_to_tsquery query
+const SearchKey = "$search"
+
+// RankColumn is the name of ts_rank virtual column added by QueryStatement.Rank
+const RankColumn = "rank"
+
+// SearchConfig sets text search configuration used on relation, default_text_search_config is used otherwise or when config is empty
+func (b *Builder) SearchConfig(relation string, config string) *Builder {
+	sch, rel := b.resolveNames(relation)
+	b.searchConfigs[sch+"."+rel] = config
+	return b
+}
+
+func (b *Builder) searchConfig(relation string) string {
+	sch, rel, err := b.lookup(relation)
+	if err != nil {
+		return ""
+	}
+
+	return b.searchConfigs[sch+"."+rel]
+}
+
+func (v ColumnData) isTsvector() bool {
+	return v.class == ClassTextSearch && v.dimension == 0
+}
+
+// searchVector returns tsvector of column, stored tsvector columns are used directly.
+// Config is emitted as literal, so expression indexes on to_tsvector(config, column) are used
+func (s *DbSchema) searchVector(v ColumnData, col string, config string) string {
+	if v.isTsvector() {
+		return col
+	}
+	if !v.IsString {
+		throw(fmt.Errorf("operator %s is not supported on column %s of type %s", SearchKey, v.JsonName, v.dataType))
+	}
+	if config == "" {
+		return "to_tsvector(" + col + ")"
+	}
+
+	return "to_tsvector(" + s.searchConfigLiteral(config) + ", " + col + ")"
+}
+
+func (s *DbSchema) searchQuery(param string, config string) string {
+	if config == "" {
+		return "websearch_to_tsquery(" + param + ")"
+	}
+
+	return "websearch_to_tsquery(" + s.searchConfigLiteral(config) + ", " + param + ")"
+}
+
+func (s *DbSchema) searchConfigLiteral(config string) string {
+	return s.SingleQuote(strings.Replace(config, "'", "''", -1)) + "::regconfig"
+}
+
+type rankColumn struct {
+	column string
+	term   string
+}
+
+// Rank adds ts_rank of column against search term as RankColumn, order by it with OrderBy(RankColumn + " DESC")
+func (s *QueryStatement) Rank(column string, term string) *QueryStatement {
+	s.rank = &rankColumn{column: column, term: term}
+	return s
+}
+
+func (s *QueryStatement) rankExpr(ctx context.Context) string {
+	col := s.targetColumn(ctx, s.rank.column)
+	config := s.builder.searchConfig(s.target)
+	vector := s.schema.searchVector(col, s.schema.Quote(col.DbName), config)
+	return "ts_rank(" + vector + ", " + s.schema.searchQuery(s.params.get(s.rank.term), config) + ") AS " + s.schema.Quote(RankColumn)
+}
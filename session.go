@@ -0,0 +1,92 @@
+package pgxjrep
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+)
+
+var ErrNoTransactions = errors.New("connection can not begin transaction required by session settings")
+
+type settingsKey struct{}
+
+// PgxBeginner is implemented by pgx connection, pool and transaction
+type PgxBeginner interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// ContextWithSettings returns context carrying session settings, e.g. "app.user_id",
+// Repository executes statements in transaction applying them with set_config(name, value, true)
+// so Row Level Security policies can read them with current_setting
+func ContextWithSettings(ctx context.Context, settings map[string]string) context.Context {
+	merged := make(map[string]string)
+	for k, v := range SettingsFromContext(ctx) {
+		merged[k] = v
+	}
+	for k, v := range settings {
+		merged[k] = v
+	}
+
+	return context.WithValue(ctx, settingsKey{}, merged)
+}
+
+func SettingsFromContext(ctx context.Context) map[string]string {
+	settings, _ := ctx.Value(settingsKey{}).(map[string]string)
+	return settings
+}
+
+// applySettings sets transaction local settings in a single round trip
+func (b *Builder) applySettings(conn PgxConn, ctx context.Context, settings map[string]string) error {
+	names := make([]string, 0, len(settings))
+	for k := range settings {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	p := &params{}
+	var exprs []string
+	for _, v := range names {
+		exprs = append(exprs, "set_config("+p.get(v)+", "+p.get(settings[v])+", true)")
+	}
+
+	_, err := b.exec(conn, ctx, "SELECT "+strings.Join(exprs, ", "), p.args)
+	return err
+}
+
+// currentSettings returns values settings have before they are applied, unset settings are returned empty
+func (b *Builder) currentSettings(conn PgxConn, ctx context.Context, settings map[string]string) (map[string]string, error) {
+	names := make([]string, 0, len(settings))
+	for k := range settings {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	p := &params{}
+	var exprs []string
+	for _, v := range names {
+		exprs = append(exprs, "COALESCE(current_setting("+p.get(v)+", true), '')")
+	}
+
+	jsn := new(string)
+	err := conn.QueryRow(ctx, "SELECT json_build_array("+strings.Join(exprs, ", ")+")::text", p.args...).Scan(jsn)
+	if err != nil {
+		return nil, err
+	}
+
+	var values []string
+	err = json.Unmarshal([]byte(*jsn), &values)
+	if err != nil {
+		return nil, err
+	}
+
+	current := make(map[string]string, len(names))
+	for i, v := range names {
+		current[v] = values[i]
+	}
+
+	return current, nil
+}
@@ -0,0 +1,116 @@
+package pgxjrep
+
+import (
+	"container/list"
+	"context"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgconn/stmtcache"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultStatementCacheSize is the number of prepared statements kept per connection, as in pgx
+const DefaultStatementCacheSize = 512
+
+// StatementCacheStats are counters of statement caches of all connections built with Builder.BuildStatementCache
+type StatementCacheStats struct {
+	Hits    uint64  `json:"hits"`
+	Misses  uint64  `json:"misses"`
+	HitRate float64 `json:"hitRate"`
+}
+
+type statementCache struct {
+	// 64-bit atomic counters are kept first for alignment on 32-bit platforms
+	hits     uint64
+	misses   uint64
+	mu       sync.RWMutex
+	size     int
+	disabled bool
+}
+
+func newStatementCache(size int) *statementCache {
+	return &statementCache{size: size}
+}
+
+func (c *statementCache) build(conn *pgconn.PgConn) stmtcache.Cache {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.disabled || c.size <= 0 {
+		return nil
+	}
+
+	return &countingCache{
+		Cache:  stmtcache.New(conn, stmtcache.ModePrepare, c.size),
+		stats:  c,
+		recent: list.New(),
+		items:  make(map[string]*list.Element),
+	}
+}
+
+func (c *statementCache) resize(size int) {
+	c.mu.Lock()
+	c.size = size
+	c.mu.Unlock()
+}
+
+func (c *statementCache) disable() {
+	c.mu.Lock()
+	c.disabled = true
+	c.mu.Unlock()
+}
+
+func (c *statementCache) stats() StatementCacheStats {
+	stats := StatementCacheStats{
+		Hits:   atomic.LoadUint64(&c.hits),
+		Misses: atomic.LoadUint64(&c.misses),
+	}
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(total)
+	}
+
+	return stats
+}
+
+// countingCache counts hits of pgx statement cache of single connection, it is not safe for concurrent use as pgx.Conn is not
+type countingCache struct {
+	stmtcache.Cache
+	stats *statementCache
+	// recent mirrors LRU order of cached statements, a hit returns the same description as previous Get of sql
+	recent *list.List
+	items  map[string]*list.Element
+}
+
+func (c *countingCache) Get(ctx context.Context, sql string) (*pgconn.StatementDescription, error) {
+	sd, err := c.Cache.Get(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+
+	if el, ok := c.items[sql]; ok {
+		if el.Value.(*pgconn.StatementDescription) == sd {
+			atomic.AddUint64(&c.stats.hits, 1)
+		} else {
+			atomic.AddUint64(&c.stats.misses, 1)
+			el.Value = sd
+		}
+		c.recent.MoveToFront(el)
+		return sd, nil
+	}
+
+	atomic.AddUint64(&c.stats.misses, 1)
+	c.items[sql] = c.recent.PushFront(sd)
+	if c.recent.Len() > c.Cap() {
+		oldest := c.recent.Back()
+		c.recent.Remove(oldest)
+		delete(c.items, oldest.Value.(*pgconn.StatementDescription).SQL)
+	}
+
+	return sd, nil
+}
+
+func (c *countingCache) Clear(ctx context.Context) error {
+	c.recent.Init()
+	c.items = make(map[string]*list.Element)
+	return c.Cache.Clear(ctx)
+}
@@ -0,0 +1,31 @@
+package pgxjrep_test
+
+import (
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestStatementCache(t *testing.T) {
+	Init(t)
+	ResetTables(t, conn, "test1", "test.\"Test2\"")
+
+	config, err := pgx.ParseConfig(os.Getenv("PGXEXEC_TEST_DSN"))
+	assert.Equal(t, nil, err)
+	config.BuildStatementCache = builder.BuildStatementCache
+	cached, err := pgx.ConnectConfig(ctx, config)
+	assert.Equal(t, nil, err)
+	defer cached.Close(ctx)
+
+	before := builder.StatementCacheStats()
+
+	_, err = builder.Query("test.Test2").Where(pk1).All(cached, ctx)
+	assert.Equal(t, nil, err)
+	_, err = builder.Query("test.Test2").Where(pk1).All(cached, ctx)
+	assert.Equal(t, nil, err)
+
+	after := builder.StatementCacheStats()
+	assert.Equal(t, before.Misses+1, after.Misses)
+	assert.Equal(t, before.Hits+1, after.Hits)
+}
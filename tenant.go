@@ -0,0 +1,40 @@
+package pgxjrep
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrMissingTenant = errors.New("context has no tenant")
+
+// TenantPolicy scopes statements on relations having Column to tenant read from context,
+// statements on such relations are refused when context carries no tenant
+type TenantPolicy struct {
+	Column string
+}
+
+type tenantKey struct{}
+
+func ContextWithTenant(ctx context.Context, tenant interface{}) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+func TenantFromContext(ctx context.Context) (interface{}, bool) {
+	tenant := ctx.Value(tenantKey{})
+	return tenant, tenant != nil
+}
+
+// scoped reports whether relation has tenant column
+func (p *TenantPolicy) scoped(schema *DbSchema, relation string) bool {
+	return p != nil && p.Column != "" && schema.ColMap(relation)[p.Column]
+}
+
+// tenant returns tenant from context, failing the build when it is missing
+func (p *TenantPolicy) tenant(ctx context.Context) interface{} {
+	tenant, ok := TenantFromContext(ctx)
+	if !ok {
+		throw(ErrMissingTenant)
+	}
+
+	return tenant
+}
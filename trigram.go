@@ -0,0 +1,62 @@
+package pgxjrep
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// trigram operator keys of where map values, available when pg_trgm extension is installed
+const (
+	SimilarKey     = "$similar"
+	WordSimilarKey = "$wordSimilar"
+)
+
+// SimilarityColumn is the name of similarity score virtual column added by QueryStatement.OrderBySimilarity
+const SimilarityColumn = "similarity"
+
+const trigramExtension = "pg_trgm"
+
+var ErrNoTrigram = errors.New("pg_trgm extension is not installed")
+
+// trigramOperator returns similarity condition, word similarity matches term against any extent of column words
+func (c *whereClause) trigramOperator(v ColumnData, op string, value interface{}) (string, bool) {
+	switch op {
+	case SimilarKey:
+		c.schema.checkTrigram(v, op)
+		return c.column(v) + " % " + c.params.get(value), true
+	case WordSimilarKey:
+		c.schema.checkTrigram(v, op)
+		return c.params.get(value) + " <% " + c.column(v), true
+	}
+
+	return "", false
+}
+
+func (s *DbSchema) checkTrigram(v ColumnData, op string) {
+	if !s.HasExtension(trigramExtension) {
+		throw(ErrNoTrigram)
+	}
+	if !v.IsString {
+		throw(fmt.Errorf("operator %s is not supported on column %s of type %s", op, v.JsonName, v.dataType))
+	}
+}
+
+type similarityColumn struct {
+	column string
+	term   string
+}
+
+// OrderBySimilarity adds similarity of column to term as SimilarityColumn and orders by it, most similar first,
+// OrderBy clause orders ties
+func (s *QueryStatement) OrderBySimilarity(column string, term string) *QueryStatement {
+	s.similarity = &similarityColumn{column: column, term: term}
+	return s
+}
+
+func (s *QueryStatement) similarityExpr(ctx context.Context) string {
+	col := s.targetColumn(ctx, s.similarity.column)
+	s.schema.checkTrigram(col, SimilarityColumn)
+
+	return "similarity(" + s.schema.Quote(col.DbName) + ", " + s.params.get(s.similarity.term) + ") AS " + s.schema.Quote(SimilarityColumn)
+}
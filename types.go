@@ -0,0 +1,96 @@
+package pgxjrep
+
+import (
+	"fmt"
+	"github.com/jackc/pgtype"
+	"strings"
+)
+
+// TypeClass classifies column type by how its values are compared, cast and returned,
+// domains are classified by their base type and arrays by their element type
+type TypeClass int
+
+const (
+	ClassOther TypeClass = iota
+	ClassString
+	ClassNumber
+	ClassBoolean
+	ClassJson
+	ClassEnum
+	ClassRange
+	ClassTextSearch
+)
+
+const tsvectorOID = 3614
+
+var typeClasses = map[int64]TypeClass{
+	pgtype.TextOID:    ClassString,
+	pgtype.VarcharOID: ClassString,
+	pgtype.BPCharOID:  ClassString,
+	pgtype.QCharOID:   ClassString,
+	pgtype.NameOID:    ClassString,
+	pgtype.Int2OID:    ClassNumber,
+	pgtype.Int4OID:    ClassNumber,
+	pgtype.Int8OID:    ClassNumber,
+	pgtype.Float4OID:  ClassNumber,
+	pgtype.Float8OID:  ClassNumber,
+	pgtype.NumericOID: ClassNumber,
+	pgtype.OIDOID:     ClassNumber,
+	pgtype.BoolOID:    ClassBoolean,
+	pgtype.JSONOID:    ClassJson,
+	pgtype.JSONBOID:   ClassJson,
+	tsvectorOID:       ClassTextSearch,
+}
+
+// classify returns class of type from oid, pg_type.typtype and pg_type.typname of base or element type,
+// extension types have no fixed oid and are recognized by unqualified name, whichever schema extension is installed in
+func classify(typeOid int64, typeType string, typeName string) TypeClass {
+	switch typeType {
+	case "e":
+		return ClassEnum
+	case "r":
+		return ClassRange
+	}
+	if class, ok := typeClasses[typeOid]; ok {
+		return class
+	}
+	if typeName == "citext" {
+		return ClassString
+	}
+
+	return ClassOther
+}
+
+// EnumError is returned when value of enum column is not one of enum labels
+type EnumError struct {
+	Relation string
+	Column   string
+	Value    interface{}
+	Labels   []string
+}
+
+func (e *EnumError) Error() string {
+	return fmt.Sprintf("column %s of relation %s must be one of %s, got %v", e.Column, e.Relation, strings.Join(e.Labels, ", "), e.Value)
+}
+
+func (v ColumnData) isEnum() bool {
+	return v.class == ClassEnum && v.dimension == 0
+}
+
+// enumValue binds value of enum column cast to enum type, failing the build on unknown label
+func (s *DbSchema) enumValue(v ColumnData, value interface{}, prm *params) string {
+	s.checkEnum(v, value)
+	return prm.get(value) + "::" + v.dataType
+}
+
+func (s *DbSchema) checkEnum(v ColumnData, value interface{}) {
+	if label, ok := value.(string); ok {
+		for _, l := range v.enum {
+			if l == label {
+				return
+			}
+		}
+	}
+
+	throw(&EnumError{Relation: v.relation, Column: v.JsonName, Value: value, Labels: v.enum})
+}
@@ -2,6 +2,11 @@ package pgxjrep
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+	"sort"
 	"strings"
 )
 
@@ -10,10 +15,15 @@ type UpdateStatement struct {
 	schema          *DbSchema
 	target          string
 	values          map[string]interface{}
+	from            []string
 	valWhrPk        bool
+	mergeJson       bool
+	version         interface{}
+	versionChecked  bool
 	whereClause     *whereClause
 	returningClause *returningClause
 	params          *params
+	err             error
 }
 
 func (s *UpdateStatement) Set(m map[string]interface{}) *UpdateStatement {
@@ -27,6 +37,38 @@ func (s *UpdateStatement) SetWherePk(m map[string]interface{}) *UpdateStatement
 	return s
 }
 
+// MergeJson merges object values of json columns into their current value instead of replacing it, as $merge does
+func (s *UpdateStatement) MergeJson() *UpdateStatement {
+	s.mergeJson = true
+	return s
+}
+
+// IfMatch updates row only if its version matches entity tag returned by OneWithETag
+func (s *UpdateStatement) IfMatch(etag string) *UpdateStatement {
+	s.version = ParseETag(etag)
+	return s
+}
+
+// From joins relations, where map keys reference their columns as "relation.column"
+func (s *UpdateStatement) From(relations ...string) *UpdateStatement {
+	s.from = relations
+	s.whereClause.relations = relations
+	s.returningClause.qualified = len(relations) > 0
+	return s
+}
+
+// WithDeleted includes soft deleted rows
+func (s *UpdateStatement) WithDeleted() *UpdateStatement {
+	s.whereClause.scope = scopeWithDeleted
+	return s
+}
+
+// OnlyDeleted updates soft deleted rows only
+func (s *UpdateStatement) OnlyDeleted() *UpdateStatement {
+	s.whereClause.scope = scopeOnlyDeleted
+	return s
+}
+
 func (s *UpdateStatement) WhereStatement(statement string, args ...interface{}) *UpdateStatement {
 	s.whereClause.statement = statement
 	s.whereClause.statementArgs = args
@@ -43,45 +85,215 @@ func (s *UpdateStatement) Filter(m map[string]interface{}) *UpdateStatement {
 	return s
 }
 
+// WhereIn limits update to rows whose columns are returned by sub query q
+func (s *UpdateStatement) WhereIn(cols []string, q *QueryStatement) *UpdateStatement {
+	s.whereClause.inCols = cols
+	s.whereClause.inQuery = q
+	return s
+}
+
 func (s *UpdateStatement) Returning(cols ...string) *UpdateStatement {
 	s.returningClause.cols = cols
 	return s
 }
 
+// ReturningAll returns all columns readable by role from context, hidden columns are left out
+func (s *UpdateStatement) ReturningAll() *UpdateStatement {
+	s.returningClause.all = true
+	return s
+}
+
 func (s *UpdateStatement) Build() (string, []interface{}) {
+	sql, args, err := s.BuildContext(context.Background())
+	if err != nil {
+		log.Panicln(err)
+	}
+
+	return sql, args
+}
+
+func (s *UpdateStatement) BuildContext(ctx context.Context) (sql string, args []interface{}, err error) {
+	defer recoverBuildError(&err)
+
+	if s.err != nil {
+		return "", nil, s.err
+	}
+
+	s.schema.checkWritable(s.target, OpUpdate)
+
 	var q = "UPDATE "
 
 	q += s.schema.QuoteRelation(s.target) + " SET "
 
+	values := s.values
+	version := s.version
+	versionCol := s.builder.versionColumn(s.target)
+	if versionCol == XminVersion {
+		if v, ok := values[XminVersion]; ok {
+			values = make(map[string]interface{}, len(s.values))
+			for k, val := range s.values {
+				values[k] = val
+			}
+			delete(values, XminVersion)
+			if version == nil {
+				version = v
+			}
+		}
+	}
+
+	values, paths := s.schema.jsonPathValues(s.target, values)
+
+	// pk conditions are collected from values again on every build, statement may be built more than once
+	s.whereClause.colData = nil
 	var vals []string
-	for _, v := range s.schema.ResolveColumnMap(s.target, s.values) {
+	for _, v := range s.schema.ResolveColumnMap(s.target, values) {
+		if !(s.valWhrPk && v.IsPk) && v.DbName != versionCol {
+			s.builder.perms.checkWrite(ctx, s.target, []ColumnData{v})
+			s.schema.validate(s.target, []ColumnData{v})
+		}
 		if s.valWhrPk && v.IsPk {
 			s.whereClause.colData = append(s.whereClause.colData, v)
+		} else if v.DbName == versionCol {
+			if version == nil {
+				version = v.Value
+			}
+		} else if s.builder.audit.protected(v.DbName) {
+			log.Warningf("Audit column %s can not be set on relation %s", v.DbName, s.target)
+		} else if s.builder.tenant.scoped(s.schema, s.target) && v.DbName == s.builder.tenant.Column {
+			log.Warningf("Tenant column %s can not be set on relation %s", v.DbName, s.target)
+		} else if ops, ok := operatorMap(v.Value); ok && v.isJson() {
+			vals = append(vals, s.schema.jsonAssignment(v, ops, paths[v.DbName], s.params))
+			delete(paths, v.DbName)
+		} else if ops, ok := operatorMap(v.Value); ok && v.isArray() {
+			vals = append(vals, s.schema.arrayAssignment(v, ops, s.params))
+		} else if m, ok := v.Value.(map[string]interface{}); ok && v.isJson() && s.mergeJson {
+			vals = append(vals, s.schema.jsonAssignment(v, map[string]interface{}{MergeKey: m}, paths[v.DbName], s.params))
+			delete(paths, v.DbName)
+		} else if len(paths[v.DbName]) > 0 {
+			throw(fmt.Errorf("column %s of relation %s is set both as a whole and by json paths", v.JsonName, s.target))
 		} else if v.Value == nil {
 			vals = append(vals, s.schema.Quote(v.DbName)+" = NULL")
+		} else if v.isRange() {
+			vals = append(vals, s.schema.Quote(v.DbName)+" = "+s.schema.rangeValue(v, v.Value, s.params))
 		} else {
 			vals = append(vals, s.schema.Quote(v.DbName)+" = "+s.params.get(v.Value))
 		}
 	}
+
+	// columns updated by json paths only
+	pathCols := make([]string, 0, len(paths))
+	for k := range paths {
+		pathCols = append(pathCols, k)
+	}
+	sort.Strings(pathCols)
+	for _, k := range pathCols {
+		col := paths[k][0]
+		s.builder.perms.checkWrite(ctx, s.target, []ColumnData{col})
+		vals = append(vals, s.schema.jsonAssignment(col, nil, paths[k], s.params))
+	}
+
+	auditCols, auditVals := s.builder.audit.assignments(ctx, s.schema, s.target, false, s.params)
+	for i, v := range auditCols {
+		vals = append(vals, v+" = "+auditVals[i])
+	}
+
+	if versionCol != "" && versionCol != XminVersion {
+		vals = append(vals, s.schema.Quote(versionCol)+" = "+s.schema.Quote(versionCol)+" + 1")
+	}
+	if versionCol != "" && version != nil {
+		s.whereClause.versionColumn = versionCol
+		s.whereClause.version = version
+		s.versionChecked = true
+	}
+
 	q += strings.Join(vals, ", ")
 
-	q += s.whereClause.build()
-	q += s.returningClause.build()
+	if len(s.from) > 0 {
+		var rels []string
+		for _, v := range s.from {
+			rels = append(rels, s.schema.QuoteRelation(v))
+		}
+		q += " FROM " + strings.Join(rels, ", ")
+	}
+
+	q += s.whereClause.build(ctx)
+	q += s.returningClause.build(ctx)
+
+	return q, s.params.args, nil
+}
 
-	return q, s.params.args
+func (s *UpdateStatement) source(p *params) {
+	s.params = p
+	s.whereClause.params = p
+	s.returningClause.nested = true
 }
 
 func (s *UpdateStatement) Exec(conn PgxConn, ctx context.Context) (string, error) {
-	sql, args := s.Build()
-	return s.builder.Exec(conn, ctx, sql, args)
+	sql, args, err := s.BuildContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	if !s.versionChecked {
+		return s.builder.Exec(conn, ctx, sql, args)
+	}
+
+	ct, err := s.builder.exec(conn, ctx, sql, args)
+	if err != nil {
+		return "", err
+	}
+	if ct.RowsAffected() == 0 {
+		return "", ErrConflict
+	}
+
+	return fmt.Sprintf("{\"rowsAffected\": %v}", ct.RowsAffected()), nil
 }
 
 func (s *UpdateStatement) One(conn PgxConn, ctx context.Context) (string, error) {
-	sql, args := s.Build()
-	return s.builder.One(conn, ctx, sql, args)
+	sql, args, err := s.BuildContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	json, err := s.builder.One(conn, ctx, sql, args)
+	return json, s.conflict(err)
 }
 
 func (s *UpdateStatement) OneMap(conn PgxConn, ctx context.Context) (map[string]interface{}, error) {
-	sql, args := s.Build()
-	return s.builder.OneMap(conn, ctx, sql, args)
+	sql, args, err := s.BuildContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := s.builder.OneMap(conn, ctx, sql, args)
+	return m, s.conflict(err)
+}
+
+// conflict translates missing row to ErrConflict when row version was checked
+func (s *UpdateStatement) conflict(err error) error {
+	if s.versionChecked && errors.Is(err, pgx.ErrNoRows) {
+		return ErrConflict
+	}
+
+	return err
+}
+
+func (s *UpdateStatement) All(conn PgxConn, ctx context.Context) (string, error) {
+	sql, args, err := s.BuildContext(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	sql = "WITH t(json) AS (" + sql + ") SELECT json_agg(t.json) as json FROM t;"
+
+	json := new(pgtype.Text)
+	err = conn.QueryRow(ctx, sql, args...).Scan(json)
+	if err != nil {
+		return "", err
+	}
+	if json.Status == pgtype.Null && s.versionChecked {
+		return "", ErrConflict
+	}
+	if json.Status == pgtype.Null {
+		return "[]", err
+	}
+
+	return json.String, nil
 }
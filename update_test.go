@@ -56,6 +56,15 @@ func TestUpdateBuild(t *testing.T) {
 		{str: builder.Update("test1").SetWherePk(update1).Returning("id", "aA"),
 			stm:  "UPDATE test1 SET a_a = $1, \"b_B\" = $2, cc_cc = NULL WHERE id = $3 RETURNING json_build_object('id', id, 'aA', a_a)",
 			args: append(args, "a", 1, 22)},
+		{str: builder.Update("test1").Set(insert1).From("test.Test2").Where(map[string]interface{}{
+			"bB":      pgxjrep.Column("Test2.id"),
+			"Test2.x": "inactive",
+		}),
+			stm:  "UPDATE test1 SET a_a = $1, \"b_B\" = $2, cc_cc = NULL FROM test.\"Test2\" WHERE test1.\"b_B\" = test.\"Test2\".\"Id\" AND test.\"Test2\".\"X\" LIKE $3",
+			args: append(args, "a", 1, "inactive")},
+		{str: builder.Update("test1").SetWherePk(update1).From("test.Test2").Returning("id"),
+			stm:  "UPDATE test1 SET a_a = $1, \"b_B\" = $2, cc_cc = NULL FROM test.\"Test2\" WHERE test1.id = $3 RETURNING json_build_object('id', test1.id)",
+			args: append(args, "a", 1, 22)},
 	}
 
 	for _, v := range buildResults {
@@ -63,6 +72,13 @@ func TestUpdateBuild(t *testing.T) {
 		assert.Equal(t, v.stm, stm)
 		assert.Equal(t, v.args, argsOut)
 	}
+
+	// statement built twice, e.g. as common table expression of Count and then All
+	upd := builder.Update("test1").SetWherePk(update1).Returning("id")
+	upd.Build()
+	stm, argsOut := upd.Build()
+	assert.Equal(t, "UPDATE test1 SET a_a = $1, \"b_B\" = $2, cc_cc = NULL WHERE id = $3 RETURNING json_build_object('id', id)", stm)
+	assert.Equal(t, append(args, "a", 1, 22), argsOut)
 }
 
 func TestUpdateExec(t *testing.T) {
@@ -123,3 +139,122 @@ func TestUpdateExec(t *testing.T) {
 	assert.Equal(t, "{\"id\":1,\"x\":\"f\",\"y\":33}", json)
 	assert.Equal(t, nil, err)
 }
+
+func TestUpdateAudit(t *testing.T) {
+	Init(t)
+	builder.SetAuditPolicy(pgxjrep.DefaultAuditPolicy())
+	defer builder.SetAuditPolicy(nil)
+
+	actorCtx := pgxjrep.ContextWithActor(ctx, "joe")
+
+	stm, argsOut, err := builder.Update("test_audit").
+		SetWherePk(map[string]interface{}{"id": 1, "name": "a", "updatedBy": "eve"}).
+		BuildContext(actorCtx)
+	assert.Equal(t, "UPDATE test_audit SET name = $1, updated_at = now(), updated_by = $2 WHERE id = $3", stm)
+	assert.Equal(t, append(args, "a", "joe", 1), argsOut)
+	assert.Equal(t, nil, err)
+}
+
+func TestUpdateVersion(t *testing.T) {
+	Init(t)
+	builder.VersionColumn("test_version", "version")
+	defer builder.VersionColumn("test_version", "")
+
+	buildResults := []updateBuild{
+		{str: builder.Update("test_version").SetWherePk(map[string]interface{}{"id": 1, "name": "a", "version": 3}),
+			stm:  "UPDATE test_version SET name = $1, version = version + 1 WHERE id = $2 AND version = $3",
+			args: append(args, "a", 1, 3)},
+		{str: builder.Update("test_version").Set(map[string]interface{}{"name": "a"}).Where(pk1).IfMatch("W/\"7\""),
+			stm:  "UPDATE test_version SET name = $1, version = version + 1 WHERE id = $2 AND version = $3",
+			args: append(args, "a", 11, "7")},
+		{str: builder.Update("test_version").Set(map[string]interface{}{"name": "a"}),
+			stm:  "UPDATE test_version SET name = $1, version = version + 1",
+			args: append(args, "a")},
+	}
+
+	for _, v := range buildResults {
+		stm, argsOut := v.str.Build()
+		assert.Equal(t, v.stm, stm)
+		assert.Equal(t, v.args, argsOut)
+	}
+}
+
+func TestUpdateJson(t *testing.T) {
+	Init(t)
+
+	stm, argsOut := builder.Update("test_jsonb").Set(map[string]interface{}{
+		"meta": map[string]interface{}{
+			"$merge":  map[string]interface{}{"theme": "dark", "legacy": nil},
+			"$remove": []interface{}{"address.zip"},
+		},
+	}).Where(pk1).Build()
+	assert.Equal(t, "UPDATE test_jsonb SET meta = (((COALESCE(meta, '{}'::jsonb) || $1::jsonb) - $2::text[]) #- $3::text[]) WHERE id = $4", stm)
+	assert.Equal(t, append(args, "{\"theme\":\"dark\"}", []string{"legacy"}, []string{"address", "zip"}, 11), argsOut)
+
+	stm, argsOut = builder.Update("test_jsonb").Set(map[string]interface{}{
+		"meta.address.city": "Oslo",
+		"doc":               map[string]interface{}{"$set": map[string]interface{}{"count": 2}},
+	}).Where(pk1).Build()
+	assert.Equal(t, "UPDATE test_jsonb SET doc = jsonb_set(COALESCE(doc::jsonb, '{}'::jsonb), $1::text[], $2::jsonb, true)::json, "+
+		"meta = (SELECT jsonb_set(jsonb_set(j, $3::text[], CASE WHEN jsonb_typeof(j #> $3::text[]) IN ('object', 'array') THEN j #> $3::text[] ELSE '{}'::jsonb END, true), "+
+		"$4::text[], $5::jsonb, true) FROM (SELECT COALESCE(meta, '{}'::jsonb) AS j) t) WHERE id = $6", stm)
+	assert.Equal(t, append(args, []string{"count"}, "2", []string{"address"}, []string{"address", "city"}, "\"Oslo\"", 11), argsOut)
+
+	stm, argsOut = builder.Update("test_jsonb").Set(map[string]interface{}{
+		"meta": map[string]interface{}{
+			"$merge": map[string]interface{}{"theme": "dark", "address": map[string]interface{}{"city": "Oslo", "zip": nil}},
+		},
+	}).Where(pk1).Build()
+	assert.Equal(t, "UPDATE test_jsonb SET meta = ((SELECT jsonb_set(jsonb_set(j, $2::text[], CASE WHEN jsonb_typeof(j #> $2::text[]) = 'object' THEN j #> $2::text[] ELSE '{}'::jsonb END, true), "+
+		"$3::text[], $4::jsonb, true) FROM (SELECT (COALESCE(meta, '{}'::jsonb) || $1::jsonb) AS j) t) #- $5::text[]) WHERE id = $6", stm)
+	assert.Equal(t, append(args, "{\"theme\":\"dark\"}", []string{"address"}, []string{"address", "city"}, "\"Oslo\"", []string{"address", "zip"}, 11), argsOut)
+
+	stm, argsOut = builder.Update("test_jsonb").MergeJson().Set(map[string]interface{}{
+		"meta": map[string]interface{}{"theme": "dark"},
+	}).Where(pk1).Build()
+	assert.Equal(t, "UPDATE test_jsonb SET meta = (COALESCE(meta, '{}'::jsonb) || $1::jsonb) WHERE id = $2", stm)
+	assert.Equal(t, append(args, "{\"theme\":\"dark\"}", 11), argsOut)
+
+	stm, argsOut = builder.Update("test_jsonb").Set(map[string]interface{}{
+		"meta": map[string]interface{}{"theme": "dark"},
+	}).Where(pk1).Build()
+	assert.Equal(t, "UPDATE test_jsonb SET meta = $1 WHERE id = $2", stm)
+	assert.Equal(t, append(args, map[string]interface{}{"theme": "dark"}, 11), argsOut)
+}
+
+func TestUpdateJsonExec(t *testing.T) {
+	Init(t)
+	ResetTables(t, conn, "test_jsonb")
+
+	_, err := conn.Exec(ctx, `INSERT INTO test_jsonb (meta) VALUES ('{"theme": "light", "address": {"street": "Main", "zip": "0150"}}')`)
+	assert.Equal(t, nil, err)
+
+	pk := map[string]interface{}{"id": 1}
+	_, err = builder.Update("test_jsonb").Set(map[string]interface{}{
+		"meta": map[string]interface{}{
+			"$merge": map[string]interface{}{"address": map[string]interface{}{"city": "Oslo", "zip": nil}},
+		},
+		"meta.limits.daily": 10,
+	}).Where(pk).Exec(conn, ctx)
+	assert.Equal(t, nil, err)
+
+	json, err := builder.Query("test_jsonb").Select("meta").Where(pk).One(conn, ctx)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "light", gjson.Get(json, "meta.theme").String())
+	assert.Equal(t, "Main", gjson.Get(json, "meta.address.street").String())
+	assert.Equal(t, "Oslo", gjson.Get(json, "meta.address.city").String())
+	assert.Equal(t, false, gjson.Get(json, "meta.address.zip").Exists())
+	assert.Equal(t, int64(10), gjson.Get(json, "meta.limits.daily").Int())
+}
+
+func TestUpdateArray(t *testing.T) {
+	Init(t)
+
+	stm, argsOut := builder.Update("test_array").Set(map[string]interface{}{
+		"tags":   map[string]interface{}{"$append": "a", "$remove": []interface{}{"b", "c"}},
+		"scores": map[string]interface{}{"$append": []int{1, 2}},
+	}).Where(pk1).Build()
+	assert.Equal(t, "UPDATE test_array SET tags = array_remove(array_remove(array_append(tags, $1::text), $2::text), $3::text), "+
+		"scores = array_cat(scores, $4::integer[]) WHERE id = $5", stm)
+	assert.Equal(t, append(args, "a", "b", "c", []int{1, 2}, 11), argsOut)
+}
@@ -0,0 +1,44 @@
+package pgxjrep
+
+import (
+	"errors"
+	"github.com/tidwall/gjson"
+	"strings"
+)
+
+// XminVersion uses PostgreSQL system column xmin as row version, no schema change is needed
+const XminVersion = "xmin"
+
+// etagColumn is appended to queries selecting row version, and removed from resulting json
+const etagColumn = "_etag"
+
+var (
+	ErrConflict        = errors.New("row was modified or deleted by concurrent transaction")
+	ErrNoVersionColumn = errors.New("relation has no version column")
+)
+
+// ETag formats row version as strong entity tag
+func ETag(version string) string {
+	return "\"" + version + "\""
+}
+
+// ParseETag returns row version from entity tag in If-Match header
+func ParseETag(etag string) string {
+	etag = strings.TrimSpace(etag)
+	etag = strings.TrimPrefix(etag, "W/")
+	return strings.Trim(etag, "\"")
+}
+
+// cutEtag removes etag column appended by QueryStatement.OneWithETag from json object,
+// other keys are kept in order with their raw values
+func cutEtag(json string) string {
+	var fields []string
+	gjson.Parse(json).ForEach(func(key, value gjson.Result) bool {
+		if key.String() != etagColumn {
+			fields = append(fields, key.Raw+":"+value.Raw)
+		}
+		return true
+	})
+
+	return "{" + strings.Join(fields, ",") + "}"
+}
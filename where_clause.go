@@ -1,10 +1,22 @@
 package pgxjrep
 
 import (
-	"github.com/sirupsen/logrus"
+	"context"
+	"sort"
 	"strings"
 )
 
+const (
+	ExistsKey    = "$exists"
+	NotExistsKey = "$notExists"
+)
+
+const (
+	scopeActive = iota
+	scopeWithDeleted
+	scopeOnlyDeleted
+)
+
 type whereClause struct {
 	schema        *DbSchema
 	target        string
@@ -13,77 +25,333 @@ type whereClause struct {
 	statementArgs []interface{}
 	values        map[string]interface{}
 	filter        map[string]interface{}
+	inCols        []string
+	inQuery       *QueryStatement
+	relations     []string
+	softDelete    string
+	searchConfig  func(relation string) string
+	scope         int
+	match         MatchMode
+	collation     string
+	versionColumn string
+	version       interface{}
+	tenant        *TenantPolicy
+	perms         *permissions
 	params        *params
 }
 
-func (c *whereClause) build() string {
+// Column is where value referencing column of target or joined relation, e.g. Column("customers.id")
+type Column string
+
+func (c *whereClause) build(ctx context.Context) string {
+	exprs := c.exprs(ctx)
+
+	scoped := c.scopeExprs(ctx)
+	if len(scoped) > 0 && c.statement != "" && len(exprs) > 0 {
+		exprs[0] = "(" + exprs[0] + ")"
+	}
+	exprs = append(exprs, scoped...)
+
+	if len(exprs) == 0 {
+		return ""
+	}
+
+	return " WHERE " + strings.Join(exprs, " AND ")
+}
+
+// scopeExprs returns conditions applied regardless of user input
+func (c *whereClause) scopeExprs(ctx context.Context) []string {
+	var exprs []string
+
+	for i, rel := range append([]string{c.target}, c.relations...) {
+		if !c.tenant.scoped(c.schema, rel) {
+			continue
+		}
+		col := ColumnData{DbName: c.tenant.Column}
+		if i > 0 {
+			col.qualifier = c.schema.QuoteRelation(rel)
+		}
+		exprs = append(exprs, c.column(col)+" = "+c.params.get(c.tenant.tenant(ctx)))
+	}
+
+	if c.versionColumn == XminVersion && c.version != nil {
+		exprs = append(exprs, c.column(ColumnData{DbName: c.versionColumn})+" = "+c.params.get(c.version)+"::text::xid")
+	} else if c.versionColumn != "" && c.version != nil {
+		exprs = append(exprs, c.column(ColumnData{DbName: c.versionColumn})+" = "+c.params.get(c.version))
+	}
+
+	if c.softDelete != "" {
+		switch c.scope {
+		case scopeActive:
+			exprs = append(exprs, c.column(ColumnData{DbName: c.softDelete})+" IS NULL")
+		case scopeOnlyDeleted:
+			exprs = append(exprs, c.column(ColumnData{DbName: c.softDelete})+" IS NOT NULL")
+		}
+	}
+
+	return exprs
+}
+
+func (c *whereClause) exprs(ctx context.Context) []string {
 	var exprs []string
 
+	if c.inQuery != nil {
+		var cols []string
+		for _, v := range c.inCols {
+			cols = append(cols, c.column(ColumnData{DbName: v}))
+		}
+
+		return append(exprs, "("+strings.Join(cols, ", ")+") IN ("+c.subquery(ctx, c.inQuery)+")")
+	}
+
 	if len(c.colData) > 0 {
+		c.perms.checkRead(ctx, c.target, c.colData)
 		for _, v := range c.colData {
-			if v.Value == nil {
-				exprs = append(exprs, c.schema.Quote(v.DbName)+" IS NULL")
-			} else if v.IsString {
-				exprs = append(exprs, c.schema.Quote(v.DbName)+" LIKE "+c.params.get(v.Value))
-			} else {
-				exprs = append(exprs, c.schema.Quote(v.DbName)+" = "+c.params.get(v.Value))
-			}
+			exprs = append(exprs, c.expr(ctx, v, false))
 		}
 
-		return " WHERE " + strings.Join(exprs, " AND ")
+		return exprs
 	}
 
 	// build statement
 	if c.statement != "" {
-		var argsLen = len(c.statementArgs)
-		var input = c.statement
-		var output string
-		var index = strings.Index(input, "?")
-		var i = 0
-		for index > 0 {
-			if i < argsLen {
-				output += input[:index] + c.params.get(c.statementArgs[i])
-			}
-			input = input[index+1:]
-			index = strings.Index(input, "?")
-			i++
-		}
-		if i > argsLen {
-			logrus.Panicf("Question mark count (%v) does not match args count (%v).", i, argsLen)
-		}
-
-		return " WHERE " + output
+		return append(exprs, c.params.bind(c.statement, c.statementArgs))
 	}
 
 	// build vals
 	if len(c.values) > 0 {
-		for _, v := range c.schema.ResolveColumnMap(c.target, c.values) {
-			if v.Value == nil {
-				exprs = append(exprs, c.schema.Quote(v.DbName)+" IS NULL")
-			} else if v.IsString {
-				exprs = append(exprs, c.schema.Quote(v.DbName)+" LIKE "+c.params.get(v.Value))
-			} else {
-				exprs = append(exprs, c.schema.Quote(v.DbName)+" = "+c.params.get(v.Value))
-			}
+		values, exists := c.existsOperands(ctx, c.values)
+		exprs = append(exprs, exists...)
+		for _, v := range c.resolve(ctx, values) {
+			exprs = append(exprs, c.expr(ctx, v, false))
 		}
 
-		return " WHERE " + strings.Join(exprs, " AND ")
+		return exprs
 	}
 
 	// build filter
 	if len(c.filter) > 0 {
-		for _, v := range c.schema.ResolveColumnMap(c.target, c.filter) {
+		filter, exists := c.existsOperands(ctx, c.filter)
+		exprs = append(exprs, exists...)
+		for _, v := range c.resolve(ctx, filter) {
 			if v.Value == nil {
 				continue
-			} else if v.IsString {
-				exprs = append(exprs, c.schema.Quote(v.DbName)+" ILIKE "+c.params.get(v.Value.(string)+"%"))
-			} else {
-				exprs = append(exprs, c.schema.Quote(v.DbName)+" = "+c.params.get(v.Value))
 			}
+			exprs = append(exprs, c.expr(ctx, v, true))
+		}
+
+		return exprs
+	}
+
+	return exprs
+}
+
+func (c *whereClause) expr(ctx context.Context, v ColumnData, filter bool) string {
+	// json path operands compare extracted value, including nil
+	if v.isJson() && len(v.path) > 0 {
+		return c.jsonExpr(ctx, v)
+	}
+
+	col := c.column(v)
+
+	switch val := v.Value.(type) {
+	case nil:
+		return col + " IS NULL"
+	case *QueryStatement:
+		return col + " IN (" + c.subquery(ctx, val) + ")"
+	case Column:
+		return col + " = " + c.columnRef(ctx, val)
+	}
+
+	if v.isJson() {
+		return c.jsonExpr(ctx, v)
+	}
+	if ops, ok := operatorMap(v.Value); ok {
+		return c.operators(ctx, v, ops)
+	}
+	if v.isRange() {
+		return col + " = " + c.schema.rangeValue(v, v.Value, c.params)
+	}
+	if v.isEnum() {
+		return col + " = " + c.schema.enumValue(v, v.Value, c.params)
+	}
+
+	if v.IsString {
+		return c.stringExpr(col, v.Value, filter)
+	}
+
+	return col + " = " + c.params.get(v.Value)
+}
+
+// resolve resolves target columns and columns of joined relations prefixed with relation name
+func (c *whereClause) resolve(ctx context.Context, m map[string]interface{}) []ColumnData {
+	m, paths := c.pathOperands(ctx, m)
+	if len(c.relations) == 0 {
+		cols := c.schema.ResolveColumnMap(c.target, m)
+		c.perms.checkRead(ctx, c.target, cols)
+		return append(cols, paths...)
+	}
+
+	relations := append([]string{c.target}, c.relations...)
+	grouped := make([]map[string]interface{}, len(relations))
+	for i := range grouped {
+		grouped[i] = make(map[string]interface{})
+	}
+	for k, v := range m {
+		i, col := c.relationIndex(relations, k)
+		grouped[i][col] = v
+	}
+
+	var colVals []ColumnData
+	for i, rel := range relations {
+		if len(grouped[i]) == 0 {
+			continue
+		}
+		cols := c.schema.ResolveColumnMap(rel, grouped[i])
+		c.perms.checkRead(ctx, rel, cols)
+		for _, v := range cols {
+			v.qualifier = c.schema.QuoteRelation(rel)
+			colVals = append(colVals, v)
+		}
+	}
+
+	return append(colVals, paths...)
+}
+
+// pathOperands splits "column.key.key" operands of json columns, of target or "relation.column.key" of joined relation,
+// from column values
+func (c *whereClause) pathOperands(ctx context.Context, m map[string]interface{}) (map[string]interface{}, []ColumnData) {
+	var paths []ColumnData
+	values := m
+	relations := append([]string{c.target}, c.relations...)
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		if strings.Contains(k, ".") {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		i, name := c.pathRelation(relations, k)
+		col, path, ok := c.schema.jsonPath(relations[i], name)
+		if !ok {
+			continue
+		}
+		c.perms.checkRead(ctx, relations[i], []ColumnData{col})
+		if i > 0 {
+			col.qualifier = c.schema.QuoteRelation(relations[i])
+		}
+		col.path = path
+		col.Value = m[k]
+		paths = append(paths, col)
+
+		if len(values) == len(m) {
+			values = make(map[string]interface{}, len(m))
+			for k, v := range m {
+				values[k] = v
+			}
+		}
+		delete(values, k)
+	}
+
+	return values, paths
+}
+
+// pathRelation finds joined relation prefixing json path key, unprefixed keys belong to target
+func (c *whereClause) pathRelation(relations []string, key string) (int, string) {
+	for i, rel := range relations[1:] {
+		_, name := c.schema.resolveNames(rel)
+		for _, prefix := range []string{rel, name} {
+			if strings.HasPrefix(key, prefix+".") {
+				return i + 1, key[len(prefix)+1:]
+			}
+		}
+	}
+
+	return 0, key
+}
+
+// relationIndex finds relation referenced by "relation.column" key, unqualified keys belong to target
+func (c *whereClause) relationIndex(relations []string, key string) (int, string) {
+	dot := strings.LastIndex(key, ".")
+	if dot < 0 {
+		return 0, key
+	}
+
+	prefix := key[:dot]
+	for i, rel := range relations {
+		if prefix == rel {
+			return i, key[dot+1:]
+		}
+		if _, name := c.schema.resolveNames(rel); prefix == name {
+			return i, key[dot+1:]
+		}
+	}
+
+	return 0, key
+}
+
+func (c *whereClause) column(v ColumnData) string {
+	if v.qualifier != "" {
+		return v.qualifier + "." + c.schema.Quote(v.DbName)
+	}
+	if len(c.relations) > 0 {
+		return c.schema.QuoteRelation(c.target) + "." + c.schema.Quote(v.DbName)
+	}
+
+	return c.schema.Quote(v.DbName)
+}
+
+func (c *whereClause) columnRef(ctx context.Context, ref Column) string {
+	relations := append([]string{c.target}, c.relations...)
+	i, col := c.relationIndex(relations, string(ref))
+	cols := c.schema.ResolveColumns(relations[i], []string{col})
+	if len(cols) == 0 {
+		log.Panicf("column %s not found", ref)
+	}
+	c.perms.checkRead(ctx, relations[i], cols)
+
+	return c.schema.QuoteRelation(relations[i]) + "." + c.schema.Quote(cols[0].DbName)
+}
+
+// existsOperands splits EXISTS / NOT EXISTS sub queries from column values
+func (c *whereClause) existsOperands(ctx context.Context, m map[string]interface{}) (map[string]interface{}, []string) {
+	var exprs []string
+	values := m
+	for _, key := range []string{ExistsKey, NotExistsKey} {
+		val, ok := m[key]
+		if !ok {
+			continue
+		}
+		if len(values) == len(m) {
+			values = make(map[string]interface{}, len(m))
+			for k, v := range m {
+				values[k] = v
+			}
+		}
+		delete(values, key)
+
+		sub, ok := val.(*QueryStatement)
+		if !ok {
+			log.Panicf("%s value must be *QueryStatement, got %T", key, val)
+		}
+		if key == ExistsKey {
+			exprs = append(exprs, "EXISTS ("+c.subquery(ctx, sub)+")")
+		} else {
+			exprs = append(exprs, "NOT EXISTS ("+c.subquery(ctx, sub)+")")
 		}
+	}
+
+	return values, exprs
+}
 
-		return " WHERE " + strings.Join(exprs, " AND ")
+func (c *whereClause) subquery(ctx context.Context, s Statement) string {
+	s.source(c.params)
+	sql, _, err := s.BuildContext(ctx)
+	if err != nil {
+		throw(err)
 	}
 
-	return ""
+	return sql
 }
@@ -0,0 +1,48 @@
+package pgxjrep
+
+import (
+	"context"
+	"strings"
+)
+
+type withClause struct {
+	schema    *DbSchema
+	recursive bool
+	ctes      []cte
+	params    *params
+}
+
+type cte struct {
+	name          string
+	statement     Statement
+	rawStatement  string
+	statementArgs []interface{}
+}
+
+func (c *withClause) build(ctx context.Context) string {
+	if len(c.ctes) == 0 {
+		return ""
+	}
+
+	var exprs []string
+	for _, v := range c.ctes {
+		var sql string
+		if v.statement != nil {
+			var err error
+			v.statement.source(c.params)
+			sql, _, err = v.statement.BuildContext(ctx)
+			if err != nil {
+				throw(err)
+			}
+		} else {
+			sql = c.params.bind(v.rawStatement, v.statementArgs)
+		}
+		exprs = append(exprs, c.schema.Quote(v.name)+" AS ("+sql+")")
+	}
+
+	if c.recursive {
+		return "WITH RECURSIVE " + strings.Join(exprs, ", ") + " "
+	}
+
+	return "WITH " + strings.Join(exprs, ", ") + " "
+}